@@ -2,8 +2,8 @@ package Brain
 
 import (
 	"Prey_Predator_MAS/config"
+	"errors"
 	"fmt"
-	"math"
 	"math/rand"
 	"sort"
 )
@@ -14,22 +14,56 @@ type Brain struct {
 	HiddenNeurons []*Neuron
 	Connections   []*Connection
 	finalDepth    int
+	// plan is the compiled form used by TakeDecision, nil until Compile
+	plan *plan
+	// MutationParams are the brain's own mutation rates in the self-adaptive mode, nil otherwise
+	MutationParams *MutationParams
+	// mutations are the ones received since the brain was created or copied, see MutationStats
+	mutations []MutationRecord
 }
 
 type Neuron struct {
+	// ID is shared by the neurons that descend from the same structural mutation
+	ID          int
 	Value       float64
 	Bias        float64
 	Connections []*Connection
 	Depth       int
+	Activation  Activation
+	// previous is the value of the last tick, read by the recurrent connections
+	previous float64
 }
 
 type Connection struct {
 	Source *Neuron
 	Target *Neuron
 	Weight float64
+	// Innovation is the historical marking used to align genes during crossover
+	Innovation int
+	// Recurrent connections carry the source value of the previous tick, they may go backwards
+	// or loop on their source and are ignored by the depth ordering
+	Recurrent bool
+	// Disabled connections are ignored by the evaluation but keep their place in the structure,
+	// so that toggleConnection can enable them again
+	Disabled bool
 }
 
-func NewBrain(numInputs, numOutputs int) *Brain {
+// Context is what a mutation needs besides the brain itself. Innovations may be nil, the
+// historical markings are then only unique within the brain. Mutations may be nil to use the
+// rates of Config, and Stats nil to count nothing.
+type Context struct {
+	Config      *config.Config
+	Rand        *rand.Rand
+	Innovations *Innovations
+	Mutations   *MutationTable
+	Stats       *MutationStats
+}
+
+// NewBrain creates a brain without hidden neurons nor connections, then applies
+// StartMutationNumber mutations. Input i has the ID i (the bias neuron being numInputs) and
+// output i the ID numInputs+1+i, so that every brain agrees on them. Mutations that find no
+// applicable operator are skipped, other mutation errors are returned.
+func NewBrain(numInputs, numOutputs int, ctx Context) (*Brain, error) {
 	brain := &Brain{
 		InputNeurons:  make([]*Neuron, numInputs+1),
 		OutputNeurons: make([]*Neuron, numOutputs),
@@ -40,6 +74,7 @@ func NewBrain(numInputs, numOutputs int) *Brain {
 
 	for i := 0; i < numInputs+1; i++ {
 		brain.InputNeurons[i] = &Neuron{
+			ID:          i,
 			Value:       0,
 			Bias:        0,
 			Connections: make([]*Connection, 0, 10),
@@ -49,102 +84,87 @@ func NewBrain(numInputs, numOutputs int) *Brain {
 
 	for i := 0; i < numOutputs; i++ {
 		brain.OutputNeurons[i] = &Neuron{
+			ID:          numInputs + 1 + i,
 			Value:       0,
 			Bias:        0,
 			Connections: make([]*Connection, 0, 10),
 			Depth:       1,
+			Activation:  defaultOutputActivation(i),
 		}
 	}
 
-	for i := 0; i < config.START_MUTATION_NUMBER; i++ {
-		brain.Mutate()
+	if ctx.Config.SelfAdaptiveMutation {
+		brain.mutationParams(ctx.Config, ctx.table())
 	}
-
-	return brain
-}
-
-func (b *Brain) Mutate() {
-	mutationRates := config.GetDefaultMutationRate()
-
-	// Handle edge case and adapt mutations rates accordingly
-	if len(b.Connections) == 0 {
-		mutationRates.WeightMutationRate = 0
-		mutationRates.DelConnectionRate = 0
-		mutationRates.NewNeuronRate = 0
+	for i := 0; i < ctx.Config.StartMutationNumber; i++ {
+		if err := brain.Mutate(ctx); err != nil && !errors.Is(err, ErrNoMutation) {
+			return nil, err
+		}
 	}
 
-	if len(b.HiddenNeurons) == 0 {
-		mutationRates.DelNeuronRate = 0
-	}
+	return brain, nil
+}
 
-	if len(b.HiddenNeurons) >= config.MAX_NEURON_NUMBER {
-		mutationRates.NewNeuronRate = 0
-	}
+// Mutate applies one operator drawn from ctx.Mutations, or from the rates of
+// ctx.Config.MutationRate when it is nil, using ctx.Rand as the only source of randomness. In
+// the self-adaptive mode the brain's own rates are used instead, see Adapt. It returns a
+// MutationError wrapping ErrNoMutation when no operator applies, the brain is then unchanged,
+// and in the debug mode one wrapping the Validate error of the mutated brain.
+func (b *Brain) Mutate(ctx Context) error {
+	b.invalidate()
 
-	chosenMutation := weightedRandom(mutationRates)
-	if chosenMutation == -1 {
-		fmt.Printf("ERROR: No mutation was chosen\n")
-		return
+	operator := ctx.table().choose(b, ctx)
+	if operator == nil {
+		return &MutationError{Err: ErrNoMutation}
 	}
+	operator.Apply(b, ctx)
+	ctx.Stats.fire(b, operator.Name())
 
-	// 0 = no mutation
-	// 1 = weight mutation
-	// 2 = bias mutation
-	// 3 = new connection
-	// 4 = del connection
-	// 5 = new neuron
-	// 6 = del neuron
-
-	switch chosenMutation {
-	case 0:
-		// No mutation
-		return
-	case 1:
-		// Weight mutation
-		b.weightMutation()
-	case 2:
-		// Bias mutation
-		b.biasMutation()
-	case 3:
-		// New connection
-		b.newConnection()
-	case 4:
-		// Del connection
-		b.delConnection()
-	case 5:
-		// New neuron
-		b.newNeuron()
-	case 6:
-		// Del neuron
-		b.delNeuron()
+	if ctx.Config.DebugBrains {
+		if err := b.Validate(); err != nil {
+			return &MutationError{Operator: operator.Name(), Err: err}
+		}
 	}
-
+	return nil
 }
 
-func (b *Brain) weightMutation() {
-	randomConIndex := rand.Intn(len(b.Connections))
+func (b *Brain) weightMutation(cfg *config.Config, r *rand.Rand) {
+	randomConIndex := r.Intn(len(b.Connections))
 	randomCon := b.Connections[randomConIndex]
 
-	change := rand.NormFloat64() * config.WEIGHT_MUTATION_STAND_DEV
+	change := r.NormFloat64() * b.weightStandDev(cfg)
 	randomCon.Weight += change
 
 	//fmt.Printf("Connection %d weight changed by %f\n", randomConIndex, change)
 }
 
-func (b *Brain) biasMutation() {
-	randomNeuronIndex := rand.Intn(len(b.HiddenNeurons) + len(b.OutputNeurons))
+// weightReset gives a random connection a new weight, drawn like the weight of a new connection.
+func (b *Brain) weightReset(cfg *config.Config, r *rand.Rand) {
+	b.Connections[r.Intn(len(b.Connections))].Weight = r.NormFloat64() * b.weightStandDev(cfg)
+}
+
+// toggleConnection disables a random enabled connection or enables a disabled one. Disabled
+// connections still order the neurons by depth, enabling one again cannot create a cycle.
+func (b *Brain) toggleConnection(r *rand.Rand) {
+	connection := b.Connections[r.Intn(len(b.Connections))]
+	connection.Disabled = !connection.Disabled
+}
+
+func (b *Brain) biasMutation(cfg *config.Config, r *rand.Rand) {
+	randomNeuronIndex := r.Intn(len(b.HiddenNeurons) + len(b.OutputNeurons))
 	if randomNeuronIndex < len(b.OutputNeurons) {
-		b.OutputNeurons[randomNeuronIndex].Bias += rand.NormFloat64() * config.BIAS_MUTATION_STAND_DEV
+		b.OutputNeurons[randomNeuronIndex].Bias += r.NormFloat64() * b.biasStandDev(cfg)
 	} else {
-		b.HiddenNeurons[randomNeuronIndex-len(b.OutputNeurons)].Bias += rand.NormFloat64() * config.BIAS_MUTATION_STAND_DEV
+		b.HiddenNeurons[randomNeuronIndex-len(b.OutputNeurons)].Bias += r.NormFloat64() * b.biasStandDev(cfg)
 	}
 
 	//fmt.Printf("Neuron %d bias changed\n", randomNeuronIndex)
 }
 
-func (b *Brain) newConnection() {
+func (b *Brain) newConnection(ctx Context) {
+	cfg, r := ctx.Config, ctx.Rand
 	// Select a random source neuron
-	randomSourceNeuronIndex := rand.Intn(len(b.InputNeurons) + len(b.HiddenNeurons))
+	randomSourceNeuronIndex := r.Intn(len(b.InputNeurons) + len(b.HiddenNeurons))
 	var sourceNeuron *Neuron
 	if randomSourceNeuronIndex < len(b.InputNeurons) {
 		sourceNeuron = b.InputNeurons[randomSourceNeuronIndex]
@@ -166,11 +186,11 @@ func (b *Brain) newConnection() {
 	}
 
 	var availableTargetNeurons []*Neuron
-    for _, neuron := range targetNeurons {
-        if !b.connectionExists(sourceNeuron, neuron) {
-            availableTargetNeurons = append(availableTargetNeurons, neuron)
-        }
-    }
+	for _, neuron := range targetNeurons {
+		if !b.connectionExists(sourceNeuron, neuron) {
+			availableTargetNeurons = append(availableTargetNeurons, neuron)
+		}
+	}
 
 	// Check if there are any available target neurons
 	if len(availableTargetNeurons) == 0 {
@@ -179,14 +199,14 @@ func (b *Brain) newConnection() {
 
 	// Select a random target neuron from this filtered subset
 
-	randomTargetNeuronIndex := rand.Intn(len(availableTargetNeurons))
-    targetNeuron := availableTargetNeurons[randomTargetNeuronIndex]
+	randomTargetNeuronIndex := r.Intn(len(availableTargetNeurons))
+	targetNeuron := availableTargetNeurons[randomTargetNeuronIndex]
 
 	// Check if connection already exists
 	// for _, connection := range sourceNeuron.Connections {
 	// 	if connection.Target == targetNeuron {
 	// 		// Strengthens connection
-	// 		connection.Weight += math.Abs(rand.NormFloat64() * config.WEIGHT_MUTATION_STAND_DEV)
+	// 		connection.Weight += math.Abs(r.NormFloat64() * cfg.WeightMutationStandDev)
 	// 	}
 	// }
 
@@ -194,8 +214,9 @@ func (b *Brain) newConnection() {
 	newConnection := &Connection{
 		Source: sourceNeuron,
 		Target: targetNeuron,
-		Weight: rand.NormFloat64() * config.WEIGHT_MUTATION_STAND_DEV,
+		Weight: r.NormFloat64() * b.weightStandDev(cfg),
 	}
+	newConnection.Innovation = ctx.Innovations.connection(b, sourceNeuron.ID, targetNeuron.ID)
 	b.Connections = append(b.Connections, newConnection)
 
 	// update source neuron's connections
@@ -207,8 +228,39 @@ func (b *Brain) newConnection() {
 	//fmt.Printf("New connection created from neuron %d to neuron %d - Depht %d - %d\n", randomSourceNeuronIndex, randomTargetNeuronIndex, sourceNeuron.Depth, targetNeuron.Depth)
 }
 
-func (b *Brain) delConnection() {
-	randomConIndex := rand.Intn(len(b.Connections))
+// newRecurrentConnection links a hidden or output neuron to itself or to a neuron that is not
+// deeper, the target then receives the source value of the previous tick.
+func (b *Brain) newRecurrentConnection(ctx Context) {
+	cfg, r := ctx.Config, ctx.Rand
+	candidates := make([]*Neuron, 0, len(b.HiddenNeurons)+len(b.OutputNeurons))
+	candidates = append(candidates, b.HiddenNeurons...)
+	candidates = append(candidates, b.OutputNeurons...)
+	sourceNeuron := candidates[r.Intn(len(candidates))]
+
+	var availableTargetNeurons []*Neuron
+	for _, neuron := range candidates {
+		if neuron.Depth <= sourceNeuron.Depth && !b.connectionExists(sourceNeuron, neuron) {
+			availableTargetNeurons = append(availableTargetNeurons, neuron)
+		}
+	}
+	if len(availableTargetNeurons) == 0 {
+		return
+	}
+	targetNeuron := availableTargetNeurons[r.Intn(len(availableTargetNeurons))]
+
+	newConnection := &Connection{
+		Source:    sourceNeuron,
+		Target:    targetNeuron,
+		Weight:    r.NormFloat64() * b.weightStandDev(cfg),
+		Recurrent: true,
+	}
+	newConnection.Innovation = ctx.Innovations.connection(b, sourceNeuron.ID, targetNeuron.ID)
+	b.Connections = append(b.Connections, newConnection)
+	sourceNeuron.Connections = append(sourceNeuron.Connections, newConnection)
+}
+
+func (b *Brain) delConnection(r *rand.Rand) {
+	randomConIndex := r.Intn(len(b.Connections))
 	randomCon := b.Connections[randomConIndex]
 
 	// remove connection from source neuron
@@ -229,43 +281,78 @@ func (b *Brain) delConnection() {
 	//fmt.Printf("Connection %d deleted\n", randomConIndex)
 }
 
-func (b *Brain) newNeuron() {
+func (b *Brain) newNeuron(ctx Context) {
 	// Select a random connection
-	randomConIndex := rand.Intn(len(b.Connections))
+	randomConIndex := ctx.Rand.Intn(len(b.Connections))
+	b.splitConnection(ctx, randomConIndex, 1, false)
+}
+
+// splitNeuron adds a neuron on a random enabled connection, NEAT style: the connection is only
+// disabled and the connection into the new neuron weighs Config.SplitNeuronWeight.
+func (b *Brain) splitNeuron(ctx Context) {
+	enabled := make([]int, 0, len(b.Connections))
+	for i, connection := range b.Connections {
+		if !connection.Disabled {
+			enabled = append(enabled, i)
+		}
+	}
+	b.splitConnection(ctx, enabled[ctx.Rand.Intn(len(enabled))], ctx.Config.SplitNeuronWeight, true)
+}
+
+// splitConnection replaces the connection at randomConIndex by a new neuron and two connections,
+// the first one weighing weight and the second one the weight of the split connection. The split
+// connection is removed, or only disabled when keep is set.
+func (b *Brain) splitConnection(ctx Context, randomConIndex int, weight float64, keep bool) {
+	cfg, r := ctx.Config, ctx.Rand
 	randomCon := b.Connections[randomConIndex]
 
 	// instantiate new neuron
 	newNeuron := &Neuron{
+		ID:          ctx.Innovations.neuron(b, randomCon.Innovation),
 		Value:       0,
-		Bias:        rand.NormFloat64() * config.BIAS_MUTATION_STAND_DEV,
+		Bias:        r.NormFloat64() * b.biasStandDev(cfg),
 		Connections: make([]*Connection, 0, 10),
 		Depth:       randomCon.Source.Depth + 1,
 	}
 
 	// create new connection from source to new neuron
 	newCon1 := &Connection{
-		Source: randomCon.Source,
-		Target: newNeuron,
-		Weight: 1,
+		Source:     randomCon.Source,
+		Target:     newNeuron,
+		Weight:     weight,
+		Innovation: ctx.Innovations.connection(b, randomCon.Source.ID, newNeuron.ID),
 	}
 
 	// create new connection from new neuron to target
 	newCon2 := &Connection{
-		Source: newNeuron,
-		Target: randomCon.Target,
-		Weight: randomCon.Weight,
+		Source:     newNeuron,
+		Target:     randomCon.Target,
+		Weight:     randomCon.Weight,
+		Innovation: ctx.Innovations.connection(b, newNeuron.ID, randomCon.Target.ID),
 	}
 
-	// remove old connection
-	for i, con := range randomCon.Source.Connections {
-		if con == randomCon {
-			randomCon.Source.Connections = append(randomCon.Source.Connections[:i], randomCon.Source.Connections[i+1:]...)
-			break
-		}
+	// both halves of a recurrent connection stay recurrent, its source may be an output neuron
+	// that is evaluated after every hidden neuron, the new neuron then only needs the lowest depth
+	if randomCon.Recurrent {
+		newCon1.Recurrent = true
+		newCon2.Recurrent = true
+		newNeuron.Depth = 1
 	}
 
-	// remove old connection from brain
-	b.Connections = append(b.Connections[:randomConIndex], b.Connections[randomConIndex+1:]...)
+	if keep {
+		randomCon.Disabled = true
+	} else {
+		// remove old connection
+		for i, con := range randomCon.Source.Connections {
+			if con == randomCon {
+				randomCon.Source.Connections = append(randomCon.Source.Connections[:i], randomCon.Source.Connections[i+1:]...)
+				break
+			}
+		}
+
+		// remove old connection from brain
+		b.Connections = append(b.Connections[:randomConIndex], b.Connections[randomConIndex+1:]...)
+	}
 
 	// add new neuron to brain
 	b.HiddenNeurons = append(b.HiddenNeurons, newNeuron)
@@ -280,14 +367,19 @@ func (b *Brain) newNeuron() {
 	newNeuron.Connections = append(newNeuron.Connections, newCon2)
 
 	// Update the depth of subsequent neurons recursively
-	b.updateDepth(randomCon.Target, newNeuron.Depth+1)
+	if randomCon.Recurrent {
+		// nothing gets deeper, this only keeps the hidden neurons sorted
+		b.updateDepth(newNeuron, newNeuron.Depth)
+	} else {
+		b.updateDepth(randomCon.Target, newNeuron.Depth+1)
+	}
 
 	//fmt.Printf("New neuron created between neuron %d and neuron %d\n", randomCon.Source, randomCon.Target)
 }
 
-func (b *Brain) delNeuron() {
+func (b *Brain) delNeuron(r *rand.Rand) {
 	// Select a random neuron
-	randomNeuronIndex := rand.Intn(len(b.HiddenNeurons))
+	randomNeuronIndex := r.Intn(len(b.HiddenNeurons))
 	randomNeuron := b.HiddenNeurons[randomNeuronIndex]
 
 	// remove neuron from brain
@@ -297,13 +389,12 @@ func (b *Brain) delNeuron() {
 	for conIndex := len(b.Connections) - 1; conIndex >= 0; conIndex-- {
 		con := b.Connections[conIndex]
 		if con.Source == randomNeuron || con.Target == randomNeuron {
-			if con.Target == randomNeuron {
-				// remove the connection from the source neuron
-				for i, sourceCon := range con.Source.Connections {
-					if con == sourceCon {
-						con.Source.Connections = append(con.Source.Connections[:i], con.Source.Connections[i+1:]...)
-						break
-					}
+			// remove the connection from its source neuron, the deleted one included so that
+			// no neuron keeps a connection the brain no longer lists
+			for i, sourceCon := range con.Source.Connections {
+				if con == sourceCon {
+					con.Source.Connections = append(con.Source.Connections[:i], con.Source.Connections[i+1:]...)
+					break
 				}
 			}
 
@@ -320,54 +411,43 @@ func (b *Brain) delNeuron() {
 	//fmt.Printf("Neuron %d deleted\n", randomNeuronIndex)
 }
 
-func (b *Brain) TakeDecision(input []float64, clr string) (speed, rotation float64) {
-	// reset hidden and output neurons
+// TakeDecisionGraph is TakeDecision walking the neuron and connection objects instead of the
+// compiled plan. Both give the same results, it is kept as the reference implementation. It
+// returns a ForeignEndpointError on a connection that leads out of the brain.
+func (b *Brain) TakeDecisionGraph(input []float64) ([]float64, error) {
+	b.invalidate()
+
+	// reset hidden and output neurons, keeping their last value for the recurrent connections
 	for _, neuron := range b.HiddenNeurons {
+		neuron.previous = neuron.Value
 		neuron.Value = 0
 	}
 
 	for _, neuron := range b.OutputNeurons {
+		neuron.previous = neuron.Value
 		neuron.Value = 0
 	}
 
-	var scaleValue float64
-	if clr == "Red" {
-		scaleValue = config.PREDATOR_RAY_LENGTH
-	} else {
-		scaleValue = config.PREY_RAY_LENGTH
+	for _, connection := range b.Connections {
+		if connection.Recurrent && !connection.Disabled {
+			connection.Target.Value += connection.Source.previous * connection.Weight
+		}
 	}
 
 	// Set input neurons values
 	for i := 0; i < len(b.InputNeurons)-1; i += 1 {
-		b.InputNeurons[i].Value = math.Abs(input[i]) / scaleValue
+		b.InputNeurons[i].Value = input[i]
 
 		// add the value of the input neuron to the value of the connected neurons
 		for _, connection := range b.InputNeurons[i].Connections {
-			if (connection.Source != nil) && (connection.Target != nil) {
-				connection.Target.Value += b.InputNeurons[i].Value * connection.Weight
-			} else {
-				fmt.Printf("ERROR: NIL target in input neuron of brain: %p\n", b)
-				return
+			if connection.Recurrent || connection.Disabled {
+				continue
+			}
+			if connection.Target == nil {
+				return nil, &ForeignEndpointError{Connection: b.connectionIndex(connection), Source: connection.Source.ID, Target: -1}
 			}
+			connection.Target.Value += b.InputNeurons[i].Value * connection.Weight
 		}
-		// inputType := input[i+1]
-		// if inputType < 0 {
-		// 	inputType = -1
-		// } else if inputType > 0 {
-		// 	inputType = 1
-		// }
-		// b.InputNeurons[i+1].Value = input[i+1]
-
-		// // add the value of the input neuron to the value of the connected neurons
-		// for _, connection := range b.InputNeurons[i+1].Connections {
-		// 	if (connection.Source != nil) && (connection.Target != nil) {
-		// 		connection.Target.Value += b.InputNeurons[i+1].Value * connection.Weight
-		// 	} else {
-		// 		fmt.Printf("ERROR: NIL target in input neuron of brain: %p\n", b)
-		// 		return
-		// 	}
-		// }
-
 	}
 
 	// Set bias neuron value
@@ -379,22 +459,28 @@ func (b *Brain) TakeDecision(input []float64, clr string) (speed, rotation float
 		neuron.Value += neuron.Bias
 
 		// apply activation function
-		neuron.Value = sigmoid(neuron.Value)
+		neuron.Value = neuron.Activation.Apply(neuron.Value)
 
 		// add the value of the hidden neuron to the value of the connected neurons
 		for _, connection := range neuron.Connections {
+			if connection.Recurrent || connection.Disabled {
+				continue
+			}
+			if connection.Target == nil {
+				return nil, &ForeignEndpointError{Connection: b.connectionIndex(connection), Source: connection.Source.ID, Target: -1}
+			}
 			connection.Target.Value += neuron.Value * connection.Weight
 		}
 	}
 
 	// Set output neurons values
-	b.OutputNeurons[0].Value += b.OutputNeurons[0].Bias
-	b.OutputNeurons[1].Value += b.OutputNeurons[1].Bias
-
-	// No activation function for speed
+	for _, neuron := range b.OutputNeurons {
+		neuron.Value = neuron.Activation.Apply(neuron.Value + neuron.Bias)
+	}
 
-	b.OutputNeurons[1].Value = tanh(b.OutputNeurons[1].Value)
-	b.OutputNeurons[1].Value *= 3.141592653589793
+	if len(b.OutputNeurons) > 1 {
+		b.OutputNeurons[1].Value *= 3.141592653589793
+	}
 	// return output neurons values
 	output := make([]float64, len(b.OutputNeurons))
 	for i, neuron := range b.OutputNeurons {
@@ -403,29 +489,30 @@ func (b *Brain) TakeDecision(input []float64, clr string) (speed, rotation float
 
 	//b.printBrainState()
 
-	return output[0], output[1]
+	return output, nil
 }
 
 func (b *Brain) printBrainState() {
+	b.sync()
 	fmt.Printf("Input neurons:\n")
 	for i, neuron := range b.InputNeurons {
 		fmt.Printf("Neuron %d: Value: %f, Bias: %f\n", i, neuron.Value, neuron.Bias)
 		for _, connection := range neuron.Connections {
-			fmt.Printf("Connection to neuron %d: Weight: %f\n", connection.Target, connection.Weight)
+			fmt.Printf("Connection to neuron %p: Weight: %f\n", connection.Target, connection.Weight)
 		}
 	}
 	fmt.Printf("Hidden neurons:\n")
 	for i, neuron := range b.HiddenNeurons {
 		fmt.Printf("Neuron %d: Value: %f, Bias: %f\n", i, neuron.Value, neuron.Bias)
 		for _, connection := range neuron.Connections {
-			fmt.Printf("Connection to neuron %d: Weight: %f\n", connection.Target, connection.Weight)
+			fmt.Printf("Connection to neuron %p: Weight: %f\n", connection.Target, connection.Weight)
 		}
 	}
 	fmt.Printf("Output neurons:\n")
 	for i, neuron := range b.OutputNeurons {
 		fmt.Printf("Neuron %d: Value: %f, Bias: %f\n", i, neuron.Value, neuron.Bias)
 		for _, connection := range neuron.Connections {
-			fmt.Printf("Connection to neuron %d: Weight: %f\n", connection.Target, connection.Weight)
+			fmt.Printf("Connection to neuron %p: Weight: %f\n", connection.Target, connection.Weight)
 		}
 	}
 }
@@ -435,7 +522,9 @@ func (b *Brain) updateDepth(neuron *Neuron, newDepth int) {
 		neuron.Depth = newDepth
 
 		for _, connection := range neuron.Connections {
-			b.updateDepth(connection.Target, newDepth+1)
+			if !connection.Recurrent {
+				b.updateDepth(connection.Target, newDepth+1)
+			}
 		}
 	}
 
@@ -446,82 +535,37 @@ func (b *Brain) updateDepth(neuron *Neuron, newDepth int) {
 
 	if len(b.HiddenNeurons) > 0 && b.HiddenNeurons[len(b.HiddenNeurons)-1].Depth >= b.finalDepth {
 		b.finalDepth = b.HiddenNeurons[len(b.HiddenNeurons)-1].Depth
-		b.OutputNeurons[0].Depth = b.finalDepth + 1
-		b.OutputNeurons[1].Depth = b.finalDepth + 1
-	}
-}
-
-func sigmoid(x float64) float64 {
-	//return 1 / (1 + math.Exp(-x))
-	return x
-}
-
-func tanh(x float64) float64 {
-	return math.Tanh(x)
-}
-func weightedRandom(mutationRates config.MutationRate) int {
-	totalWeight := mutationRates.NoMutation +
-		mutationRates.WeightMutationRate +
-		mutationRates.BiasMutationRate +
-		mutationRates.NewConnectionRate +
-		mutationRates.DelConnectionRate +
-		mutationRates.NewNeuronRate +
-		mutationRates.DelNeuronRate
-
-	ranNum := rand.Intn(totalWeight)
-
-	if ranNum < mutationRates.NoMutation {
-		return 0
-	}
-	ranNum -= mutationRates.NoMutation
-
-	if ranNum < mutationRates.WeightMutationRate {
-		return 1
-	}
-	ranNum -= mutationRates.WeightMutationRate
-
-	if ranNum < mutationRates.BiasMutationRate {
-		return 2
-	}
-	ranNum -= mutationRates.BiasMutationRate
-
-	if ranNum < mutationRates.NewConnectionRate {
-		return 3
-	}
-	ranNum -= mutationRates.NewConnectionRate
-
-	if ranNum < mutationRates.DelConnectionRate {
-		return 4
-	}
-	ranNum -= mutationRates.DelConnectionRate
-
-	if ranNum < mutationRates.NewNeuronRate {
-		return 5
+		for _, neuron := range b.OutputNeurons {
+			neuron.Depth = b.finalDepth + 1
+		}
 	}
-
-	return 6
-
 }
 
-func (b *Brain) Copy() *Brain {
+// Copy returns a deep copy of the brain, or a ForeignEndpointError if a connection has an
+// endpoint that is not one of its neurons.
+func (b *Brain) Copy() (*Brain, error) {
+	b.sync()
 	neuronMap := make(map[*Neuron]*Neuron) // Map to track old to new neuron mapping
 
 	// Create new Brain instance
 	brain := &Brain{
-		InputNeurons:  make([]*Neuron, len(b.InputNeurons)),
-		OutputNeurons: make([]*Neuron, len(b.OutputNeurons)),
-		HiddenNeurons: make([]*Neuron, len(b.HiddenNeurons)),
-		Connections:   make([]*Connection, len(b.Connections)),
-		finalDepth:    b.finalDepth,
+		InputNeurons:   make([]*Neuron, len(b.InputNeurons)),
+		OutputNeurons:  make([]*Neuron, len(b.OutputNeurons)),
+		HiddenNeurons:  make([]*Neuron, len(b.HiddenNeurons)),
+		Connections:    make([]*Connection, len(b.Connections)),
+		finalDepth:     b.finalDepth,
+		MutationParams: b.MutationParams.copy(),
 	}
 
 	// Copy input neurons and fill the map
 	for i, oldNeuron := range b.InputNeurons {
 		newNeuron := &Neuron{
+			ID:          oldNeuron.ID,
 			Value:       oldNeuron.Value,
 			Bias:        oldNeuron.Bias,
 			Connections: make([]*Connection, 0, len(oldNeuron.Connections)), // Initially empty
 			Depth:       oldNeuron.Depth,
+			Activation:  oldNeuron.Activation,
 		}
 		neuronMap[oldNeuron] = newNeuron
 		brain.InputNeurons[i] = newNeuron
@@ -530,10 +574,12 @@ func (b *Brain) Copy() *Brain {
 	// Copy hidden neurons
 	for i, oldNeuron := range b.HiddenNeurons {
 		newNeuron := &Neuron{
+			ID:          oldNeuron.ID,
 			Value:       oldNeuron.Value,
 			Bias:        oldNeuron.Bias,
 			Connections: make([]*Connection, 0, len(oldNeuron.Connections)),
 			Depth:       oldNeuron.Depth,
+			Activation:  oldNeuron.Activation,
 		}
 		neuronMap[oldNeuron] = newNeuron
 		brain.HiddenNeurons[i] = newNeuron
@@ -542,10 +588,12 @@ func (b *Brain) Copy() *Brain {
 	// Copy output neurons
 	for i, oldNeuron := range b.OutputNeurons {
 		newNeuron := &Neuron{
+			ID:          oldNeuron.ID,
 			Value:       oldNeuron.Value,
 			Bias:        oldNeuron.Bias,
 			Connections: make([]*Connection, 0, len(oldNeuron.Connections)),
 			Depth:       oldNeuron.Depth,
+			Activation:  oldNeuron.Activation,
 		}
 		neuronMap[oldNeuron] = newNeuron
 		brain.OutputNeurons[i] = newNeuron
@@ -554,12 +602,16 @@ func (b *Brain) Copy() *Brain {
 	// Copy connections and update neuron references
 	for i, oldConnection := range b.Connections {
 		newConnection := &Connection{
-			Source: neuronMap[oldConnection.Source],
-			Target: neuronMap[oldConnection.Target],
-			Weight: oldConnection.Weight,
+			Source:     neuronMap[oldConnection.Source],
+			Target:     neuronMap[oldConnection.Target],
+			Weight:     oldConnection.Weight,
+			Innovation: oldConnection.Innovation,
+			Recurrent:  oldConnection.Recurrent,
+			Disabled:   oldConnection.Disabled,
 		}
 		if newConnection.Source == nil || newConnection.Target == nil {
-			fmt.Printf("ERROR: nil source or target in new brain connection\n")
+			// the connection is not part of b, the copy could not be evaluated
+			return nil, &ForeignEndpointError{Connection: i, Source: endpointID(oldConnection.Source, newConnection.Source), Target: endpointID(oldConnection.Target, newConnection.Target)}
 		}
 		brain.Connections[i] = newConnection
 
@@ -568,62 +620,83 @@ func (b *Brain) Copy() *Brain {
 	}
 
 	//fmt.Printf("New brain created: %p\n", brain)
-	return brain
+	return brain, nil
 }
 
 type NeuronViewModel struct {
-	ID    uint16	`json:"id"`
-	Value float64	`json:"value"`
-	Depth int		`json:"depth"`
+	ID         uint16  `json:"id"`
+	Value      float64 `json:"value"`
+	Depth      int     `json:"depth"`
+	Activation string  `json:"activation"`
+	// Label names the sensor of an input neuron
+	Label string `json:"label,omitempty"`
 }
 
 type ConnectionViewModel struct {
-	Source uint16	`json:"source"`
-	Target uint16	`json:"target"`
-	Weight float64	`json:"weight"`
+	Source    uint16  `json:"source"`
+	Target    uint16  `json:"target"`
+	Weight    float64 `json:"weight"`
+	Recurrent bool    `json:"recurrent"`
+	Disabled  bool    `json:"disabled"`
 }
 
 type BrainViewModel struct {
-	Neurons     []*NeuronViewModel		`json:"neurons"`
-	Connections []*ConnectionViewModel	`json:"connections"`
+	Neurons     []*NeuronViewModel     `json:"neurons"`
+	Connections []*ConnectionViewModel `json:"connections"`
 }
 
-func NewBrainViewModel(brain *Brain) *BrainViewModel {
+// NewBrainViewModel describes brain for the UI, labels naming its input neurons but the bias one.
+// It syncs the neuron values of brain, which must not be evaluated or copied meanwhile.
+func NewBrainViewModel(brain *Brain, labels []string) *BrainViewModel {
+	brain.sync()
 	neurons := make([]*NeuronViewModel, 0, len(brain.InputNeurons)+len(brain.HiddenNeurons)+len(brain.OutputNeurons))
 	connections := make([]*ConnectionViewModel, 0, len(brain.Connections))
 	mapNeuronID := make(map[*Neuron]uint16)
 	for i, neuron := range brain.InputNeurons {
+		label := "bias"
+		if i < len(brain.InputNeurons)-1 {
+			label = ""
+			if i < len(labels) {
+				label = labels[i]
+			}
+		}
 		neurons = append(neurons, &NeuronViewModel{
-			ID:    uint16(i),
-			Value: neuron.Value,
-			Depth: neuron.Depth,
+			ID:         uint16(i),
+			Value:      neuron.Value,
+			Depth:      neuron.Depth,
+			Activation: neuron.Activation.String(),
+			Label:      label,
 		})
 		mapNeuronID[neuron] = uint16(i)
 	}
 
 	for i, neuron := range brain.HiddenNeurons {
 		neurons = append(neurons, &NeuronViewModel{
-			ID:    uint16(i + len(brain.InputNeurons)),
-			Value: neuron.Value,
-			Depth: neuron.Depth,
+			ID:         uint16(i + len(brain.InputNeurons)),
+			Value:      neuron.Value,
+			Depth:      neuron.Depth,
+			Activation: neuron.Activation.String(),
 		})
 		mapNeuronID[neuron] = uint16(i + len(brain.InputNeurons))
 	}
 
 	for i, neuron := range brain.OutputNeurons {
 		neurons = append(neurons, &NeuronViewModel{
-			ID:    uint16(i + len(brain.InputNeurons) + len(brain.HiddenNeurons)),
-			Value: neuron.Value,
-			Depth: neuron.Depth,
+			ID:         uint16(i + len(brain.InputNeurons) + len(brain.HiddenNeurons)),
+			Value:      neuron.Value,
+			Depth:      neuron.Depth,
+			Activation: neuron.Activation.String(),
 		})
 		mapNeuronID[neuron] = uint16(i + len(brain.InputNeurons) + len(brain.HiddenNeurons))
 	}
 
 	for _, connection := range brain.Connections {
 		connections = append(connections, &ConnectionViewModel{
-			Source: mapNeuronID[connection.Source],
-			Target: mapNeuronID[connection.Target],
-			Weight: connection.Weight,
+			Source:    mapNeuronID[connection.Source],
+			Target:    mapNeuronID[connection.Target],
+			Weight:    connection.Weight,
+			Recurrent: connection.Recurrent,
+			Disabled:  connection.Disabled,
 		})
 	}
 
@@ -634,10 +707,10 @@ func NewBrainViewModel(brain *Brain) *BrainViewModel {
 }
 
 func (b *Brain) connectionExists(source, target *Neuron) bool {
-    for _, conn := range b.Connections {
-        if conn.Source == source && conn.Target == target {
-            return true
-        }
-    }
-    return false
-}
\ No newline at end of file
+	for _, conn := range b.Connections {
+		if conn.Source == source && conn.Target == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package Brain
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Activation is the function a neuron applies to the sum of its inputs and bias.
+type Activation uint8
+
+const (
+	Identity Activation = iota
+	Sigmoid
+	Tanh
+	ReLU
+	Gaussian
+	Sin
+	Step
+	activationCount
+)
+
+var activationNames = [activationCount]string{"identity", "sigmoid", "tanh", "relu", "gaussian", "sin", "step"}
+
+func (a Activation) Apply(x float64) float64 {
+	switch a {
+	case Sigmoid:
+		return 1 / (1 + math.Exp(-x))
+	case Tanh:
+		return math.Tanh(x)
+	case ReLU:
+		return math.Max(0, x)
+	case Gaussian:
+		return math.Exp(-x * x)
+	case Sin:
+		return math.Sin(x)
+	case Step:
+		if x > 0 {
+			return 1
+		}
+		return 0
+	}
+	return x
+}
+
+func (a Activation) String() string {
+	if a < activationCount {
+		return activationNames[a]
+	}
+	return fmt.Sprintf("Activation(%d)", uint8(a))
+}
+
+// ParseActivation returns the activation named name, as written by String.
+func ParseActivation(name string) (Activation, error) {
+	for i, activationName := range activationNames {
+		if name == activationName {
+			return Activation(i), nil
+		}
+	}
+	return Identity, fmt.Errorf("unknown activation %q", name)
+}
+
+// MarshalText writes the activation name in json genomes.
+func (a Activation) MarshalText() ([]byte, error) {
+	if a >= activationCount {
+		return nil, fmt.Errorf("unknown activation %d", uint8(a))
+	}
+	return []byte(a.String()), nil
+}
+
+func (a *Activation) UnmarshalText(text []byte) error {
+	activation, err := ParseActivation(string(text))
+	if err != nil {
+		return err
+	}
+	*a = activation
+	return nil
+}
+
+// defaultOutputActivation keeps the historical outputs: the speed is linear and the rotation
+// goes through tanh.
+func defaultOutputActivation(output int) Activation {
+	if output == 1 {
+		return Tanh
+	}
+	return Identity
+}
+
+// activationMutation gives a random hidden neuron another activation.
+func (b *Brain) activationMutation(r *rand.Rand) {
+	neuron := b.HiddenNeurons[r.Intn(len(b.HiddenNeurons))]
+	neuron.Activation = Activation((int(neuron.Activation) + 1 + r.Intn(int(activationCount)-1)) % int(activationCount))
+}
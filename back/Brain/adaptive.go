@@ -0,0 +1,180 @@
+package Brain
+
+import (
+	"Prey_Predator_MAS/config"
+	"math"
+	"sort"
+)
+
+// bounds of the self-adapted parameters, so that long runs neither freeze nor overflow
+const (
+	minMutationParam = 1e-6
+	maxMutationParam = 1e6
+)
+
+// MutationParams are the mutation rates and standard deviations a brain carries in the
+// self-adaptive mode (config.Config.SelfAdaptiveMutation).
+type MutationParams struct {
+	// Rates weights the registered operators by name, like config.MutationRate
+	Rates          map[string]float64 `json:"rates"`
+	WeightStandDev float64            `json:"weightStandDev"`
+	BiasStandDev   float64            `json:"biasStandDev"`
+}
+
+func (p *MutationParams) copy() *MutationParams {
+	if p == nil {
+		return nil
+	}
+	rates := make(map[string]float64, len(p.Rates))
+	for name, rate := range p.Rates {
+		rates[name] = rate
+	}
+	return &MutationParams{Rates: rates, WeightStandDev: p.WeightStandDev, BiasStandDev: p.BiasStandDev}
+}
+
+// names returns the operators of the rates in order, so that they are mutated deterministically.
+func (p *MutationParams) names() []string {
+	names := make([]string, 0, len(p.Rates))
+	for name := range p.Rates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mutationParams returns the parameters of b, giving it those of the config and table first if
+// it has none, e.g. a blank brain or a genome saved without self-adaptation.
+func (b *Brain) mutationParams(cfg *config.Config, table *MutationTable) *MutationParams {
+	if b.MutationParams == nil {
+		b.MutationParams = &MutationParams{
+			Rates:          make(map[string]float64, len(table.operators)),
+			WeightStandDev: cfg.WeightMutationStandDev,
+			BiasStandDev:   cfg.BiasMutationStandDev,
+		}
+		for i, operator := range table.operators {
+			b.MutationParams.Rates[operator.Name()] = float64(table.rates[i])
+		}
+	}
+	return b.MutationParams
+}
+
+// Adapt mutates the mutation parameters of b, evolution strategy style: each of them is
+// multiplied by exp(SelfAdaptationRate * N(0, 1)). It is called once per birth, before the
+// mutations, and does nothing unless the self-adaptive mode is on.
+func (b *Brain) Adapt(ctx Context) {
+	cfg, r := ctx.Config, ctx.Rand
+	if !cfg.SelfAdaptiveMutation {
+		return
+	}
+	p := b.mutationParams(cfg, ctx.table())
+	adapt := func(value float64) float64 {
+		return math.Min(maxMutationParam, math.Max(minMutationParam, value*math.Exp(cfg.SelfAdaptationRate*r.NormFloat64())))
+	}
+	p.WeightStandDev = adapt(p.WeightStandDev)
+	p.BiasStandDev = adapt(p.BiasStandDev)
+	for _, name := range p.names() {
+		// a disabled operator stays disabled
+		if p.Rates[name] > 0 {
+			p.Rates[name] = adapt(p.Rates[name])
+		}
+	}
+}
+
+// StepSizes returns the standard deviations of the weight and bias mutations of b, its own ones
+// when it carries mutation parameters.
+func (b *Brain) StepSizes(cfg *config.Config) (weight, bias float64) {
+	if b.MutationParams != nil {
+		return b.MutationParams.WeightStandDev, b.MutationParams.BiasStandDev
+	}
+	return cfg.WeightMutationStandDev, cfg.BiasMutationStandDev
+}
+
+func (b *Brain) weightStandDev(cfg *config.Config) float64 {
+	weight, _ := b.StepSizes(cfg)
+	return weight
+}
+
+func (b *Brain) biasStandDev(cfg *config.Config) float64 {
+	_, bias := b.StepSizes(cfg)
+	return bias
+}
+
+// recombine gives the child the mean of the mutation parameters of both parents, the
+// intermediate recombination of evolution strategies.
+func recombine(child, other *Brain) {
+	if child.MutationParams == nil || other.MutationParams == nil {
+		return
+	}
+	p, q := child.MutationParams, other.MutationParams
+	p.WeightStandDev = (p.WeightStandDev + q.WeightStandDev) / 2
+	p.BiasStandDev = (p.BiasStandDev + q.BiasStandDev) / 2
+	for name, rate := range p.Rates {
+		p.Rates[name] = (rate + q.Rates[name]) / 2
+	}
+}
+
+// Summary is the mean, minimum and maximum of a parameter over a population.
+type Summary struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+}
+
+func (s *Summary) add(value float64, n int) {
+	if n == 0 {
+		*s = Summary{Min: value, Max: value}
+	}
+	s.Mean += value
+	s.Min = math.Min(s.Min, value)
+	s.Max = math.Max(s.Max, value)
+}
+
+// MutationParamsSummary describes the mutation parameters evolved by a population. Rates are
+// summarized as probabilities, each brain's rates being divided by their sum.
+type MutationParamsSummary struct {
+	// Brains is the number of brains carrying mutation parameters
+	Brains         int                `json:"brains"`
+	WeightStandDev Summary            `json:"weightStandDev"`
+	BiasStandDev   Summary            `json:"biasStandDev"`
+	Rates          map[string]Summary `json:"rates"`
+}
+
+// SummarizeMutationParams summarizes the parameters of the brains that carry some.
+func SummarizeMutationParams(brains []*Brain) MutationParamsSummary {
+	summary := MutationParamsSummary{Rates: make(map[string]Summary)}
+	counts := make(map[string]int)
+	for _, b := range brains {
+		p := b.MutationParams
+		if p == nil {
+			continue
+		}
+		summary.WeightStandDev.add(p.WeightStandDev, summary.Brains)
+		summary.BiasStandDev.add(p.BiasStandDev, summary.Brains)
+		summary.Brains++
+
+		total := 0.0
+		names := p.names()
+		for _, name := range names {
+			total += p.Rates[name]
+		}
+		if total == 0 {
+			continue
+		}
+		for _, name := range names {
+			rate := summary.Rates[name]
+			rate.add(p.Rates[name]/total, counts[name])
+			summary.Rates[name] = rate
+			counts[name]++
+		}
+	}
+
+	if summary.Brains > 0 {
+		summary.WeightStandDev.Mean /= float64(summary.Brains)
+		summary.BiasStandDev.Mean /= float64(summary.Brains)
+	}
+	for name, rate := range summary.Rates {
+		rate.Mean /= float64(counts[name])
+		summary.Rates[name] = rate
+	}
+	return summary
+}
@@ -0,0 +1,58 @@
+package Brain
+
+import (
+	"Prey_Predator_MAS/config"
+	"math"
+)
+
+// CompatibilityDistance measures how far apart two brains are genetically, see
+// config.Speciation. Connections are compared through their innovation numbers: a gene present
+// in one brain only is excess when its number is beyond the other brain's highest one and
+// disjoint otherwise.
+func CompatibilityDistance(a, b *Brain, cfg config.Speciation) float64 {
+	weightsA, maxA := innovationWeights(a)
+	weightsB, maxB := innovationWeights(b)
+
+	excess, disjoint, matching := 0, 0, 0
+	weightDifference := 0.0
+	// slices are walked rather than maps so that the float sum is deterministic
+	for _, connection := range a.Connections {
+		if other, ok := weightsB[connection.Innovation]; ok {
+			matching++
+			weightDifference += math.Abs(connection.Weight - other)
+		} else if connection.Innovation > maxB {
+			excess++
+		} else {
+			disjoint++
+		}
+	}
+	for _, connection := range b.Connections {
+		if _, ok := weightsA[connection.Innovation]; ok {
+			continue
+		} else if connection.Innovation > maxA {
+			excess++
+		} else {
+			disjoint++
+		}
+	}
+
+	distance := cfg.HiddenCoefficient * math.Abs(float64(len(a.HiddenNeurons)-len(b.HiddenNeurons)))
+	if size := max(len(a.Connections), len(b.Connections)); size > 0 {
+		distance += (cfg.ExcessCoefficient*float64(excess) + cfg.DisjointCoefficient*float64(disjoint)) / float64(size)
+	}
+	if matching > 0 {
+		distance += cfg.WeightCoefficient * weightDifference / float64(matching)
+	}
+	return distance
+}
+
+// innovationWeights maps the innovation numbers of the brain to their weights.
+func innovationWeights(b *Brain) (map[int]float64, int) {
+	weights := make(map[int]float64, len(b.Connections))
+	highest := 0
+	for _, connection := range b.Connections {
+		weights[connection.Innovation] = connection.Weight
+		highest = max(highest, connection.Innovation)
+	}
+	return weights, highest
+}
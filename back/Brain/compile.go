@@ -0,0 +1,141 @@
+package Brain
+
+// plan is a brain flattened into arrays. Neurons are indexed inputs first (the bias neuron
+// being the last input), then hidden neurons in evaluation order, then outputs. The outgoing
+// connections of neuron i are targets[edgeStart[i]:edgeStart[i+1]], in the order of
+// Neuron.Connections so that sums are computed in the same order as the graph walk.
+type plan struct {
+	inputs, hidden int
+
+	values     []float64
+	previous   []float64
+	bias       []float64
+	activation []Activation
+	edgeStart  []int32
+	targets    []int32
+	weights    []float64
+
+	recurrentSources []int32
+	recurrentTargets []int32
+	recurrentWeights []float64
+
+	// neurons receive the values back when the brain is read, see sync
+	neurons []*Neuron
+}
+
+// Compile builds the flat evaluation plan used by TakeDecision. It is called on the first
+// decision after NewBrain, Copy, Mutate or SetState, so calling it is only needed after
+// modifying the brain fields directly.
+func (b *Brain) Compile() {
+	b.sync()
+	count := len(b.InputNeurons) + len(b.HiddenNeurons) + len(b.OutputNeurons)
+	p := &plan{
+		inputs:     len(b.InputNeurons),
+		hidden:     len(b.HiddenNeurons),
+		values:     make([]float64, count),
+		previous:   make([]float64, count),
+		bias:       make([]float64, count),
+		activation: make([]Activation, count),
+		edgeStart:  make([]int32, count+1),
+		targets:    make([]int32, 0, len(b.Connections)),
+		weights:    make([]float64, 0, len(b.Connections)),
+		neurons:    make([]*Neuron, 0, count),
+	}
+
+	indexes := make(map[*Neuron]int32, count)
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			index := len(p.neurons)
+			indexes[neuron] = int32(index)
+			p.neurons = append(p.neurons, neuron)
+			p.values[index] = neuron.Value
+			p.bias[index] = neuron.Bias
+			p.activation[index] = neuron.Activation
+		}
+	}
+
+	for i, neuron := range p.neurons {
+		p.edgeStart[i] = int32(len(p.targets))
+		for _, connection := range neuron.Connections {
+			if !connection.Recurrent && !connection.Disabled {
+				p.targets = append(p.targets, indexes[connection.Target])
+				p.weights = append(p.weights, connection.Weight)
+			}
+		}
+	}
+	p.edgeStart[count] = int32(len(p.targets))
+
+	for _, connection := range b.Connections {
+		if connection.Recurrent && !connection.Disabled {
+			p.recurrentSources = append(p.recurrentSources, indexes[connection.Source])
+			p.recurrentTargets = append(p.recurrentTargets, indexes[connection.Target])
+			p.recurrentWeights = append(p.recurrentWeights, connection.Weight)
+		}
+	}
+
+	b.plan = p
+}
+
+// TakeDecision feeds the inputs, normalized by the caller, through the network and returns the
+// output values, the second one (the rotation) being scaled by π. It does not allocate once the
+// brain is compiled, the returned slice is overwritten by the next decision.
+func (b *Brain) TakeDecision(input []float64) []float64 {
+	if b.plan == nil {
+		b.Compile()
+	}
+	p := b.plan
+	values := p.values
+	hiddenStart, outputStart := p.inputs, p.inputs+p.hidden
+
+	// reset hidden and output neurons, keeping their last value for the recurrent connections
+	copy(p.previous[hiddenStart:], values[hiddenStart:])
+	clear(values[hiddenStart:])
+	for i, source := range p.recurrentSources {
+		values[p.recurrentTargets[i]] += p.previous[source] * p.recurrentWeights[i]
+	}
+
+	// the bias neuron does not propagate, as in the graph walk
+	for i := 0; i < p.inputs-1; i++ {
+		value := input[i]
+		values[i] = value
+		for e := p.edgeStart[i]; e < p.edgeStart[i+1]; e++ {
+			values[p.targets[e]] += value * p.weights[e]
+		}
+	}
+	values[p.inputs-1] = 1
+
+	for i := hiddenStart; i < outputStart; i++ {
+		value := p.activation[i].Apply(values[i] + p.bias[i])
+		values[i] = value
+		for e := p.edgeStart[i]; e < p.edgeStart[i+1]; e++ {
+			values[p.targets[e]] += value * p.weights[e]
+		}
+	}
+
+	for i := outputStart; i < len(values); i++ {
+		values[i] = p.activation[i].Apply(values[i] + p.bias[i])
+	}
+	if len(values) > outputStart+1 {
+		values[outputStart+1] *= 3.141592653589793
+	}
+
+	return values[outputStart:]
+}
+
+// sync copies the values computed by the plan into the neurons. The plan keeps them in its own
+// array while the brain is only evaluated, everything that reads or changes the neurons calls
+// sync first.
+func (b *Brain) sync() {
+	if b.plan == nil {
+		return
+	}
+	for i, neuron := range b.plan.neurons {
+		neuron.Value = b.plan.values[i]
+	}
+}
+
+// invalidate drops the plan after syncing its values, the next decision compiles a new one.
+func (b *Brain) invalidate() {
+	b.sync()
+	b.plan = nil
+}
@@ -0,0 +1,203 @@
+package Brain
+
+import (
+	"Prey_Predator_MAS/config"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// loadConfig reads the -config and -set flags given after -args, e.g.
+//
+//	go test -run '^$' -bench Think ./Brain -args -set 'species.prey.sensors=["energy"]'
+var loadConfig = config.RegisterFlags(flag.CommandLine)
+
+// benchSizes are the populations of the benchmarks: the original 2,600 agents and a crowded world.
+var benchSizes = []int{2600, 20000}
+
+// benchMutations gives the benchmark brains evolved-like sizes.
+const benchMutations = 60
+
+// randomBrains returns size brains of the config's layout, each mutated mutations times, and one
+// input vector in [0, 1[ for each of them.
+func randomBrains(tb testing.TB, cfg *config.Config, size, mutations int, seed int64) ([]*Brain, [][]float64) {
+	r := rand.New(rand.NewSource(seed))
+	ctx := Context{Config: cfg, Rand: r, Innovations: NewInnovations(cfg.InputCount(), cfg.OutputNeuronNumber)}
+	brains := make([]*Brain, size)
+	inputs := make([][]float64, size)
+	for i := range brains {
+		brain, err := NewBrain(cfg.InputCount(), cfg.OutputNeuronNumber, ctx)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		for m := 0; m < mutations; m++ {
+			if err := brain.Mutate(ctx); err != nil && !errors.Is(err, ErrNoMutation) {
+				tb.Fatal(err)
+			}
+		}
+		brains[i] = brain
+		inputs[i] = make([]float64, cfg.InputCount())
+		for j := range inputs[i] {
+			inputs[i][j] = r.Float64()
+		}
+	}
+	return brains, inputs
+}
+
+// TestCompileMatchesGraph checks that the compiled brains decide exactly as the graph walk,
+// over several ticks so that the recurrent connections read the previous values, and after a
+// mutation drops the compiled plan.
+func TestCompileMatchesGraph(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *config.Config)
+	}{
+		{name: "default", change: func(cfg *config.Config) {}},
+		{name: "recurrent", change: func(cfg *config.Config) {
+			cfg.RecurrentConnections = true
+			cfg.MutationRate.NewRecurrentConnectionRate = 20
+		}},
+		{name: "disabled and split connections", change: func(cfg *config.Config) {
+			cfg.MutationRate.ToggleConnectionRate = 10
+			cfg.MutationRate.SplitNeuronRate = 10
+		}},
+		{name: "signal and nearest", change: func(cfg *config.Config) {
+			cfg.Outputs = []string{config.OUTPUT_SIGNAL}
+			cfg.OutputNeuronNumber = config.OUTPUT_NEURON_NUMBER + 1
+			cfg.RayChannels = []string{config.CHANNEL_NEAREST}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			tt.change(&cfg)
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			brains, _ := randomBrains(t, &cfg, 50, benchMutations, 3)
+			r := rand.New(rand.NewSource(4))
+			input := make([]float64, cfg.InputCount())
+			for i, compiled := range brains {
+				graph, err := compiled.Copy()
+				if err != nil {
+					t.Fatal(err)
+				}
+				for tick := 0; tick < 6; tick++ {
+					if tick == 3 {
+						// the same mutation for both, the plan of the compiled brain is dropped
+						for _, brain := range []*Brain{compiled, graph} {
+							ctx := Context{Config: &cfg, Rand: rand.New(rand.NewSource(int64(i)))}
+							if err := brain.Mutate(ctx); err != nil && !errors.Is(err, ErrNoMutation) {
+								t.Fatal(err)
+							}
+						}
+					}
+					for j := range input {
+						input[j] = r.Float64()
+					}
+					want, err := graph.TakeDecisionGraph(input)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got := compiled.TakeDecision(input); !slices.Equal(want, got) {
+						t.Errorf("brain %d, tick %d: compiled %v, graph %v", i, tick, got, want)
+					}
+				}
+				if !slices.Equal(compiled.State(), graph.State()) {
+					t.Errorf("brain %d: compiled state %v, graph state %v", i, compiled.State(), graph.State())
+				}
+			}
+		})
+	}
+}
+
+// evaluation is a way of running a decision for every brain of a population.
+type evaluation struct {
+	name string
+	run  func(brains []*Brain, inputs [][]float64) error
+}
+
+// benchPopulations runs every evaluation on the brains of every size of benchSizes.
+func benchPopulations(b *testing.B, evaluations []evaluation) {
+	cfg, err := loadConfig()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, size := range benchSizes {
+		brains, inputs := randomBrains(b, &cfg, size, benchMutations, config.SEED)
+		for _, evaluation := range evaluations {
+			b.Run(fmt.Sprintf("agents=%d/%s", size, evaluation.name), func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					if err := evaluation.run(brains, inputs); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*size), "ns/agent")
+			})
+		}
+	}
+}
+
+// BenchmarkThink runs the think phase of a tick the way Environment.Step used to, one goroutine
+// per agent walking the neuron graph, and the way it does now, compiled brains evaluated in one
+// chunk per CPU.
+func BenchmarkThink(b *testing.B) {
+	benchPopulations(b, []evaluation{
+		{"graph", func(brains []*Brain, inputs [][]float64) error {
+			errs := make([]error, len(brains))
+			var wg sync.WaitGroup
+			wg.Add(len(brains))
+			for i, brain := range brains {
+				go func(i int, brain *Brain) {
+					defer wg.Done()
+					_, errs[i] = brain.TakeDecisionGraph(inputs[i])
+				}(i, brain)
+			}
+			wg.Wait()
+			return errors.Join(errs...)
+		}},
+		{"compiled", func(brains []*Brain, inputs [][]float64) error {
+			chunkSize := (len(brains) + runtime.GOMAXPROCS(0) - 1) / runtime.GOMAXPROCS(0)
+			var wg sync.WaitGroup
+			for start := 0; start < len(brains); start += chunkSize {
+				end := min(start+chunkSize, len(brains))
+				wg.Add(1)
+				go func(brains []*Brain, inputs [][]float64) {
+					defer wg.Done()
+					for i, brain := range brains {
+						brain.TakeDecision(inputs[i])
+					}
+				}(brains[start:end], inputs[start:end])
+			}
+			wg.Wait()
+			return nil
+		}},
+	})
+}
+
+// BenchmarkDecision isolates the cost of a decision, evaluating the brains in sequence.
+func BenchmarkDecision(b *testing.B) {
+	benchPopulations(b, []evaluation{
+		{"graph", func(brains []*Brain, inputs [][]float64) error {
+			for i, brain := range brains {
+				if _, err := brain.TakeDecisionGraph(inputs[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"compiled", func(brains []*Brain, inputs [][]float64) error {
+			for i, brain := range brains {
+				brain.TakeDecision(inputs[i])
+			}
+			return nil
+		}},
+	})
+}
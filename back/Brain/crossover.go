@@ -0,0 +1,48 @@
+package Brain
+
+import (
+	"math/rand"
+)
+
+// Crossover creates the brain of an offspring. As in NEAT the structure, disjoint and excess
+// genes come from the fitter parent, while the matching genes (same innovation number for a
+// connection, same ID for a neuron) take the weight and enabled state, or bias and activation, of
+// either parent at random. Self-adaptive mutation parameters are averaged. It returns the error
+// of copying the fitter parent.
+func Crossover(fitter, other *Brain, r *rand.Rand) (*Brain, error) {
+	child, err := fitter.Copy()
+	if err != nil {
+		return nil, err
+	}
+	recombine(child, other)
+
+	otherConnections := make(map[int]*Connection, len(other.Connections))
+	for _, connection := range other.Connections {
+		if connection.Innovation != 0 {
+			otherConnections[connection.Innovation] = connection
+		}
+	}
+	for _, connection := range child.Connections {
+		if match, ok := otherConnections[connection.Innovation]; ok && r.Intn(2) == 1 {
+			connection.Weight = match.Weight
+			connection.Disabled = match.Disabled
+		}
+	}
+
+	otherNeurons := make(map[int]*Neuron, len(other.HiddenNeurons)+len(other.OutputNeurons))
+	for _, layer := range [][]*Neuron{other.HiddenNeurons, other.OutputNeurons} {
+		for _, neuron := range layer {
+			otherNeurons[neuron.ID] = neuron
+		}
+	}
+	for _, layer := range [][]*Neuron{child.HiddenNeurons, child.OutputNeurons} {
+		for _, neuron := range layer {
+			if match, ok := otherNeurons[neuron.ID]; ok && r.Intn(2) == 1 {
+				neuron.Bias = match.Bias
+				neuron.Activation = match.Activation
+			}
+		}
+	}
+
+	return child, nil
+}
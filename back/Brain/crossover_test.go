@@ -0,0 +1,146 @@
+package Brain
+
+import (
+	"Prey_Predator_MAS/config"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// evolve applies n mutations to brain.
+func evolve(t *testing.T, brain *Brain, ctx Context, n int) *Brain {
+	for i := 0; i < n; i++ {
+		if err := brain.Mutate(ctx); err != nil && !errors.Is(err, ErrNoMutation) {
+			t.Fatal(err)
+		}
+	}
+	return brain
+}
+
+// TestCrossover checks that the offspring has the structure of the fitter parent, that every
+// matching gene comes from either parent and that the parents are left unchanged.
+func TestCrossover(t *testing.T) {
+	tests := []struct {
+		name         string
+		selfAdaptive bool
+		// parents returns the fitter and the other parent
+		parents func(t *testing.T, ctx Context) (*Brain, *Brain)
+	}{
+		{name: "itself", parents: func(t *testing.T, ctx Context) (*Brain, *Brain) {
+			brain := evolve(t, newTestBrain(t, ctx), ctx, 40)
+			return brain, brain
+		}},
+		{name: "siblings", parents: siblings},
+		{name: "self-adaptive siblings", selfAdaptive: true, parents: siblings},
+		{name: "strangers", parents: func(t *testing.T, ctx Context) (*Brain, *Brain) {
+			return evolve(t, newTestBrain(t, ctx), ctx, 40), evolve(t, newTestBrain(t, ctx), ctx, 40)
+		}},
+		{name: "new brain and evolved brain", parents: func(t *testing.T, ctx Context) (*Brain, *Brain) {
+			return newTestBrain(t, ctx), evolve(t, newTestBrain(t, ctx), ctx, 40)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.RecurrentConnections = true
+			cfg.SelfAdaptiveMutation = tt.selfAdaptive
+			cfg.MutationRate.ToggleConnectionRate = 5
+			ctx := Context{Config: &cfg, Rand: rand.New(rand.NewSource(5)), Innovations: NewInnovations(cfg.InputCount(), cfg.OutputNeuronNumber)}
+			fitter, other := tt.parents(t, ctx)
+			fitterGenome, otherGenome := fitter.Genome(), other.Genome()
+
+			for seed := int64(0); seed < 10; seed++ {
+				child, err := Crossover(fitter, other, rand.New(rand.NewSource(seed)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := child.Validate(); err != nil {
+					t.Fatalf("invalid offspring: %v", err)
+				}
+				checkOffspring(t, child.Genome(), fitterGenome, otherGenome)
+			}
+
+			if !reflect.DeepEqual(fitter.Genome(), fitterGenome) || !reflect.DeepEqual(other.Genome(), otherGenome) {
+				t.Error("crossover changed a parent")
+			}
+		})
+	}
+}
+
+// newTestBrain is a brain of the config layout without mutations.
+func newTestBrain(t *testing.T, ctx Context) *Brain {
+	brain, err := NewBrain(ctx.Config.InputCount(), ctx.Config.OutputNeuronNumber, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return brain
+}
+
+// siblings are two copies of an evolved brain that evolved apart.
+func siblings(t *testing.T, ctx Context) (*Brain, *Brain) {
+	parent := evolve(t, newTestBrain(t, ctx), ctx, 40)
+	first, err := parent.Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := parent.Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Adapt(ctx)
+	second.Adapt(ctx)
+	return evolve(t, first, ctx, 20), evolve(t, second, ctx, 20)
+}
+
+// checkOffspring reports the genes of child that do not come from the fitter parent, or for the
+// matching ones from the other parent.
+func checkOffspring(t *testing.T, child, fitter, other Genome) {
+	t.Helper()
+	if len(child.Neurons) != len(fitter.Neurons) || len(child.Connections) != len(fitter.Connections) {
+		t.Fatalf("offspring has %d neurons and %d connections, the fitter parent %d and %d",
+			len(child.Neurons), len(child.Connections), len(fitter.Neurons), len(fitter.Connections))
+	}
+
+	otherNeurons := make(map[int]NeuronGene)
+	for _, gene := range other.Neurons[other.Inputs:] {
+		otherNeurons[gene.ID] = gene
+	}
+	for i, gene := range child.Neurons {
+		fitterGene := fitter.Neurons[i]
+		match, matches := otherNeurons[gene.ID]
+		switch {
+		case gene.ID != fitterGene.ID || gene.Depth != fitterGene.Depth:
+			t.Errorf("neuron %d is %+v, the fitter parent has %+v", i, gene, fitterGene)
+		case gene.Bias == fitterGene.Bias && gene.Activation == fitterGene.Activation:
+		case matches && i >= child.Inputs && gene.Bias == match.Bias && gene.Activation == match.Activation:
+		default:
+			t.Errorf("neuron %d is %+v, from neither %+v nor %+v", i, gene, fitterGene, match)
+		}
+	}
+
+	otherConnections := make(map[int]ConnectionGene)
+	for _, gene := range other.Connections {
+		otherConnections[gene.Innovation] = gene
+	}
+	for i, gene := range child.Connections {
+		fitterGene := fitter.Connections[i]
+		match, matches := otherConnections[gene.Innovation]
+		switch {
+		case gene.Source != fitterGene.Source || gene.Target != fitterGene.Target || gene.Innovation != fitterGene.Innovation || gene.Recurrent != fitterGene.Recurrent:
+			t.Errorf("connection %d is %+v, the fitter parent has %+v", i, gene, fitterGene)
+		case gene.Weight == fitterGene.Weight && gene.Disabled == fitterGene.Disabled:
+		case matches && gene.Weight == match.Weight && gene.Disabled == match.Disabled:
+		default:
+			t.Errorf("connection %d is %+v, from neither %+v nor %+v", i, gene, fitterGene, match)
+		}
+	}
+
+	if fitter.MutationParams != nil && other.MutationParams != nil {
+		want := (fitter.MutationParams.WeightStandDev + other.MutationParams.WeightStandDev) / 2
+		if child.MutationParams.WeightStandDev != want {
+			t.Errorf("offspring weight standard deviation %g, want the mean %g", child.MutationParams.WeightStandDev, want)
+		}
+	}
+}
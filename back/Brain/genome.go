@@ -0,0 +1,163 @@
+package Brain
+
+import (
+	"fmt"
+)
+
+// GENOME_VERSION is bumped whenever the genome layout changes, UnmarshalGenome must then read
+// the genomes of the older versions.
+const GENOME_VERSION = 1
+
+// NeuronGene is the serializable form of a Neuron.
+type NeuronGene struct {
+	ID         int        `json:"id"`
+	Bias       float64    `json:"bias"`
+	Depth      int        `json:"depth"`
+	Activation Activation `json:"activation"`
+}
+
+// ConnectionGene links two neurons by their index in Genome.Neurons.
+type ConnectionGene struct {
+	Source     int     `json:"source"`
+	Target     int     `json:"target"`
+	Weight     float64 `json:"weight"`
+	Innovation int     `json:"innovation"`
+	Recurrent  bool    `json:"recurrent,omitempty"`
+	Disabled   bool    `json:"disabled,omitempty"`
+}
+
+// Genome is the pointer-free form of a Brain. Neurons are ordered inputs (the last one being
+// the bias neuron), then hidden neurons, then outputs. Connections keep the brain order, which
+// is also the evaluation order of every neuron's outgoing connections.
+type Genome struct {
+	Version     int              `json:"version"`
+	Inputs      int              `json:"inputs"`
+	Hidden      int              `json:"hidden"`
+	Outputs     int              `json:"outputs"`
+	FinalDepth  int              `json:"finalDepth"`
+	Neurons     []NeuronGene     `json:"neurons"`
+	Connections []ConnectionGene `json:"connections"`
+	// MutationParams are only set in the self-adaptive mode
+	MutationParams *MutationParams `json:"mutationParams,omitempty"`
+}
+
+// Genome returns a copy of the brain structure that does not share memory with it.
+func (b *Brain) Genome() Genome {
+	g := Genome{
+		Version:        GENOME_VERSION,
+		Inputs:         len(b.InputNeurons),
+		Hidden:         len(b.HiddenNeurons),
+		Outputs:        len(b.OutputNeurons),
+		FinalDepth:     b.finalDepth,
+		Neurons:        make([]NeuronGene, 0, len(b.InputNeurons)+len(b.HiddenNeurons)+len(b.OutputNeurons)),
+		Connections:    make([]ConnectionGene, 0, len(b.Connections)),
+		MutationParams: b.MutationParams.copy(),
+	}
+
+	indexes := make(map[*Neuron]int, cap(g.Neurons))
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			indexes[neuron] = len(g.Neurons)
+			g.Neurons = append(g.Neurons, NeuronGene{
+				ID:         neuron.ID,
+				Bias:       neuron.Bias,
+				Depth:      neuron.Depth,
+				Activation: neuron.Activation,
+			})
+		}
+	}
+
+	for _, connection := range b.Connections {
+		g.Connections = append(g.Connections, ConnectionGene{
+			Source:     indexes[connection.Source],
+			Target:     indexes[connection.Target],
+			Weight:     connection.Weight,
+			Innovation: connection.Innovation,
+			Recurrent:  connection.Recurrent,
+			Disabled:   connection.Disabled,
+		})
+	}
+	return g
+}
+
+// FromGenome rebuilds a brain, it fails if the genome references missing neurons or if the
+// brain does not pass Validate.
+func FromGenome(g Genome) (*Brain, error) {
+	if g.Inputs < 1 || g.Outputs < 1 || g.Hidden < 0 {
+		return nil, fmt.Errorf("genome has %d inputs, %d hidden and %d outputs neurons", g.Inputs, g.Hidden, g.Outputs)
+	}
+	if len(g.Neurons) != g.Inputs+g.Hidden+g.Outputs {
+		return nil, fmt.Errorf("genome declares %d neurons but lists %d", g.Inputs+g.Hidden+g.Outputs, len(g.Neurons))
+	}
+
+	neurons := make([]*Neuron, len(g.Neurons))
+	for i, gene := range g.Neurons {
+		neurons[i] = &Neuron{
+			ID:          gene.ID,
+			Bias:        gene.Bias,
+			Depth:       gene.Depth,
+			Activation:  gene.Activation,
+			Connections: make([]*Connection, 0, 10),
+		}
+	}
+
+	brain := &Brain{
+		InputNeurons:  neurons[:g.Inputs:g.Inputs],
+		HiddenNeurons: append(make([]*Neuron, 0, g.Hidden+10), neurons[g.Inputs:g.Inputs+g.Hidden]...),
+		OutputNeurons: neurons[g.Inputs+g.Hidden:],
+		Connections:   make([]*Connection, 0, len(g.Connections)),
+		finalDepth:    g.FinalDepth,
+	}
+
+	for i, gene := range g.Connections {
+		if gene.Source < 0 || gene.Source >= len(neurons) || gene.Target < 0 || gene.Target >= len(neurons) {
+			return nil, fmt.Errorf("connection %d references a missing neuron (%d -> %d)", i, gene.Source, gene.Target)
+		}
+		connection := &Connection{
+			Source:     neurons[gene.Source],
+			Target:     neurons[gene.Target],
+			Weight:     gene.Weight,
+			Innovation: gene.Innovation,
+			Recurrent:  gene.Recurrent,
+			Disabled:   gene.Disabled,
+		}
+		brain.Connections = append(brain.Connections, connection)
+		connection.Source.Connections = append(connection.Source.Connections, connection)
+	}
+
+	brain.MutationParams = g.MutationParams.copy()
+
+	if err := brain.Validate(); err != nil {
+		return nil, err
+	}
+	return brain, nil
+}
+
+// State returns the value of every neuron, in genome order. Recurrent connections read these
+// values on the next tick, so they are part of a saved world.
+func (b *Brain) State() []float64 {
+	b.sync()
+	state := make([]float64, 0, len(b.InputNeurons)+len(b.HiddenNeurons)+len(b.OutputNeurons))
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			state = append(state, neuron.Value)
+		}
+	}
+	return state
+}
+
+// SetState restores values returned by State.
+func (b *Brain) SetState(state []float64) error {
+	if len(state) != len(b.InputNeurons)+len(b.HiddenNeurons)+len(b.OutputNeurons) {
+		return fmt.Errorf("state has %d values for %d neurons", len(state), len(b.InputNeurons)+len(b.HiddenNeurons)+len(b.OutputNeurons))
+	}
+	i := 0
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			neuron.Value = state[i]
+			i++
+		}
+	}
+	b.plan = nil
+	return nil
+}
@@ -0,0 +1,254 @@
+package Brain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+type GenomeFormat int
+
+const (
+	GenomeJSON GenomeFormat = iota
+	GenomeBinary
+)
+
+// genomeMagic starts every binary genome, it is followed by the version byte.
+var genomeMagic = []byte("PPGN")
+
+// ParseGenomeFormat accepts "json" and "binary".
+func ParseGenomeFormat(name string) (GenomeFormat, error) {
+	switch name {
+	case "json", "":
+		return GenomeJSON, nil
+	case "binary", "bin":
+		return GenomeBinary, nil
+	}
+	return GenomeJSON, fmt.Errorf("unknown genome format %q", name)
+}
+
+// MarshalGenome encodes the brain losslessly, either as indented json or as a compact binary.
+func (b *Brain) MarshalGenome(format GenomeFormat) ([]byte, error) {
+	g := b.Genome()
+	switch format {
+	case GenomeJSON:
+		return json.MarshalIndent(g, "", "  ")
+	case GenomeBinary:
+		return g.marshalBinary(), nil
+	}
+	return nil, fmt.Errorf("unknown genome format %d", format)
+}
+
+// UnmarshalGenome decodes a genome written by MarshalGenome, the format is detected.
+func UnmarshalGenome(data []byte) (*Brain, error) {
+	var g Genome
+	if bytes.HasPrefix(data, genomeMagic) {
+		var err error
+		if g, err = unmarshalBinaryGenome(data); err != nil {
+			return nil, fmt.Errorf("genome: %w", err)
+		}
+	} else if err := json.Unmarshal(data, &g); err != nil {
+		return nil, fmt.Errorf("genome: %w", err)
+	}
+
+	if g.Version != GENOME_VERSION {
+		return nil, fmt.Errorf("genome: unsupported version %d, expected %d", g.Version, GENOME_VERSION)
+	}
+	return FromGenome(g)
+}
+
+// marshalBinary writes counts and indexes as uvarints and floats as little endian float64.
+func (g Genome) marshalBinary() []byte {
+	buf := make([]byte, 0, 16+len(g.Neurons)*10+len(g.Connections)*12)
+	buf = append(buf, genomeMagic...)
+	buf = append(buf, byte(GENOME_VERSION))
+	buf = binary.AppendUvarint(buf, uint64(g.Inputs))
+	buf = binary.AppendUvarint(buf, uint64(g.Hidden))
+	buf = binary.AppendUvarint(buf, uint64(g.Outputs))
+	buf = binary.AppendUvarint(buf, uint64(g.FinalDepth))
+
+	for _, neuron := range g.Neurons {
+		buf = binary.AppendUvarint(buf, uint64(neuron.ID))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(neuron.Bias))
+		buf = binary.AppendUvarint(buf, uint64(neuron.Depth))
+		buf = append(buf, byte(neuron.Activation))
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(g.Connections)))
+	for _, connection := range g.Connections {
+		buf = binary.AppendUvarint(buf, uint64(connection.Source))
+		buf = binary.AppendUvarint(buf, uint64(connection.Target))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(connection.Weight))
+		buf = binary.AppendUvarint(buf, uint64(connection.Innovation))
+		var flags byte
+		if connection.Recurrent {
+			flags |= 1
+		}
+		if connection.Disabled {
+			flags |= 2
+		}
+		buf = append(buf, flags)
+	}
+
+	// the mutation parameters follow a presence byte, rates sorted by name
+	if p := g.MutationParams; p == nil {
+		buf = append(buf, 0)
+	} else {
+		buf = append(buf, 1)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p.WeightStandDev))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p.BiasStandDev))
+		buf = binary.AppendUvarint(buf, uint64(len(p.Rates)))
+		for _, name := range p.names() {
+			buf = binary.AppendUvarint(buf, uint64(len(name)))
+			buf = append(buf, name...)
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(p.Rates[name]))
+		}
+	}
+	return buf
+}
+
+func unmarshalBinaryGenome(data []byte) (Genome, error) {
+	var g Genome
+	r := bufio.NewReader(bytes.NewReader(data[len(genomeMagic):]))
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return g, err
+	}
+	g.Version = int(version)
+
+	var counts [4]uint64
+	for i := range counts {
+		if counts[i], err = binary.ReadUvarint(r); err != nil {
+			return g, err
+		}
+	}
+	g.Inputs, g.Hidden, g.Outputs, g.FinalDepth = int(counts[0]), int(counts[1]), int(counts[2]), int(counts[3])
+
+	// every neuron takes at least 9 bytes, refuse counts the data cannot hold
+	neuronCount := counts[0] + counts[1] + counts[2]
+	if neuronCount > uint64(len(data))/9 {
+		return g, errors.New("neuron count exceeds data size")
+	}
+	g.Neurons = make([]NeuronGene, neuronCount)
+	for i := range g.Neurons {
+		id, err := binary.ReadUvarint(r)
+		if err != nil {
+			return g, err
+		}
+		g.Neurons[i].ID = int(id)
+		if g.Neurons[i].Bias, err = readFloat(r); err != nil {
+			return g, err
+		}
+		depth, err := binary.ReadUvarint(r)
+		if err != nil {
+			return g, err
+		}
+		g.Neurons[i].Depth = int(depth)
+		activation, err := r.ReadByte()
+		if err != nil {
+			return g, err
+		}
+		if Activation(activation) >= activationCount {
+			return g, fmt.Errorf("unknown activation %d", activation)
+		}
+		g.Neurons[i].Activation = Activation(activation)
+	}
+
+	connectionCount, err := binary.ReadUvarint(r)
+	if err != nil {
+		return g, err
+	}
+	if connectionCount > uint64(len(data))/10 {
+		return g, errors.New("connection count exceeds data size")
+	}
+	g.Connections = make([]ConnectionGene, connectionCount)
+	for i := range g.Connections {
+		source, err := binary.ReadUvarint(r)
+		if err != nil {
+			return g, err
+		}
+		target, err := binary.ReadUvarint(r)
+		if err != nil {
+			return g, err
+		}
+		g.Connections[i].Source, g.Connections[i].Target = int(source), int(target)
+		if g.Connections[i].Weight, err = readFloat(r); err != nil {
+			return g, err
+		}
+		innovation, err := binary.ReadUvarint(r)
+		if err != nil {
+			return g, err
+		}
+		g.Connections[i].Innovation = int(innovation)
+		flags, err := r.ReadByte()
+		if err != nil {
+			return g, err
+		}
+		g.Connections[i].Recurrent = flags&1 != 0
+		g.Connections[i].Disabled = flags&2 != 0
+	}
+
+	if g.MutationParams, err = readMutationParams(r, len(data)); err != nil {
+		return g, err
+	}
+
+	if _, err := r.ReadByte(); err != io.EOF {
+		return g, errors.New("trailing data after genome")
+	}
+	return g, nil
+}
+
+// readMutationParams refuses counts and lengths larger than size, the size of the genome.
+func readMutationParams(r *bufio.Reader, size int) (*MutationParams, error) {
+	present, err := r.ReadByte()
+	if err != nil || present == 0 {
+		return nil, err
+	}
+	p := &MutationParams{}
+	if p.WeightStandDev, err = readFloat(r); err != nil {
+		return nil, err
+	}
+	if p.BiasStandDev, err = readFloat(r); err != nil {
+		return nil, err
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	// every rate takes at least 9 bytes
+	if count > uint64(size)/9 {
+		return nil, fmt.Errorf("genome lists %d mutation rates", count)
+	}
+	p.Rates = make(map[string]float64, count)
+	for i := uint64(0); i < count; i++ {
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if length > uint64(size) {
+			return nil, errors.New("mutation operator name longer than the genome")
+		}
+		name := make([]byte, length)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, err
+		}
+		if p.Rates[string(name)], err = readFloat(r); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func readFloat(r io.Reader) (float64, error) {
+	var bits [8]byte
+	if _, err := io.ReadFull(r, bits[:]); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(bits[:])), nil
+}
@@ -0,0 +1,94 @@
+package Brain
+
+import (
+	"Prey_Predator_MAS/config"
+	"bytes"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// TestGenomeRoundTrip checks that a brain decoded from its genome has the same genome and takes
+// the same decisions, recurrent state included.
+func TestGenomeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       GenomeFormat
+		mutations    int
+		selfAdaptive bool
+	}{
+		{name: "json new brain", format: GenomeJSON},
+		{name: "json evolved", format: GenomeJSON, mutations: 60},
+		{name: "json self-adaptive", format: GenomeJSON, mutations: 60, selfAdaptive: true},
+		{name: "binary new brain", format: GenomeBinary},
+		{name: "binary evolved", format: GenomeBinary, mutations: 60},
+		{name: "binary self-adaptive", format: GenomeBinary, mutations: 60, selfAdaptive: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.SelfAdaptiveMutation = tt.selfAdaptive
+			brains, inputs := randomBrains(t, &cfg, 20, tt.mutations, 1)
+			for i, brain := range brains {
+				data, err := brain.MarshalGenome(tt.format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded, err := UnmarshalGenome(data)
+				if err != nil {
+					t.Fatalf("brain %d: %v", i, err)
+				}
+				if !reflect.DeepEqual(brain.Genome(), decoded.Genome()) {
+					t.Errorf("brain %d: genome %+v decoded as %+v", i, brain.Genome(), decoded.Genome())
+				}
+				again, err := decoded.MarshalGenome(tt.format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(data, again) {
+					t.Errorf("brain %d: the decoded brain encodes differently", i)
+				}
+				for tick := 0; tick < 3; tick++ {
+					want, got := brain.TakeDecision(inputs[i]), decoded.TakeDecision(inputs[i])
+					if !slices.Equal(want, got) {
+						t.Errorf("brain %d, tick %d: decision %v, decoded %v", i, tick, want, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestUnmarshalGenomeRejects checks that damaged genomes are reported instead of decoded.
+func TestUnmarshalGenomeRejects(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	brains, _ := randomBrains(t, &cfg, 1, 60, 2)
+	binary, err := brains[0].MarshalGenome(GenomeBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json, err := brains[0].MarshalGenome(GenomeJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "truncated binary", data: binary[:len(binary)/2]},
+		{name: "truncated json", data: json[:len(json)/2]},
+		{name: "unknown version", data: bytes.Replace(json, []byte(`"version": 1`), []byte(`"version": 99`), 1)},
+		{name: "missing neuron", data: bytes.Replace(json, []byte(`"inputs": `), []byte(`"inputs": 1`), 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := UnmarshalGenome(tt.data); err == nil {
+				t.Error("damaged genome decoded without error")
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package Brain
+
+import (
+	"sort"
+	"sync"
+)
+
+// Innovations hands out the historical markings of a population: a connection between the same
+// two neurons always gets the same innovation number, and splitting the same connection always
+// creates a neuron with the same ID, whichever brain the mutation happens in.
+type Innovations struct {
+	lock           sync.Mutex
+	connections    map[[2]int]int
+	splits         map[int]int
+	nextInnovation int
+	nextNeuron     int
+}
+
+// InnovationsState is the serializable form of Innovations.
+type InnovationsState struct {
+	NextInnovation int `json:"nextInnovation"`
+	NextNeuron     int `json:"nextNeuron"`
+	// Connections lists source ID, target ID and innovation number
+	Connections [][3]int `json:"connections"`
+	// Splits lists the innovation number of a split connection and the ID of the created neuron
+	Splits [][2]int `json:"splits"`
+}
+
+// NewInnovations creates the tracker of a population of brains created by NewBrain with the
+// same number of inputs and outputs.
+func NewInnovations(numInputs, numOutputs int) *Innovations {
+	return &Innovations{
+		connections:    make(map[[2]int]int),
+		splits:         make(map[int]int),
+		nextInnovation: 1,
+		nextNeuron:     numInputs + 1 + numOutputs,
+	}
+}
+
+// connection returns the innovation number of a connection from source to target.
+func (t *Innovations) connection(b *Brain, source, target int) int {
+	if t == nil {
+		innovation := 0
+		for _, connection := range b.Connections {
+			innovation = max(innovation, connection.Innovation)
+		}
+		return innovation + 1
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	key := [2]int{source, target}
+	if innovation, ok := t.connections[key]; ok {
+		return innovation
+	}
+	innovation := t.nextInnovation
+	t.nextInnovation++
+	t.connections[key] = innovation
+	return innovation
+}
+
+// neuron returns the ID of the neuron created by splitting the connection split. A fresh ID is
+// used when b already holds the neuron, which happens when the connection was split, deleted,
+// created again and split again.
+func (t *Innovations) neuron(b *Brain, split int) int {
+	if t == nil {
+		return b.maxNeuronID() + 1
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if id, ok := t.splits[split]; ok && !b.hasNeuron(id) {
+		return id
+	}
+	id := t.nextNeuron
+	t.nextNeuron++
+	if _, ok := t.splits[split]; !ok && split != 0 {
+		t.splits[split] = id
+	}
+	return id
+}
+
+// Adopt renumbers a brain that was not created by this population, e.g. a loaded genome. Its
+// hidden neurons get fresh IDs and its connections the innovation numbers of this population.
+// Copies of the brain made after adopting it share their markings.
+func (t *Innovations) Adopt(b *Brain) {
+	t.lock.Lock()
+	for _, neuron := range b.HiddenNeurons {
+		neuron.ID = t.nextNeuron
+		t.nextNeuron++
+	}
+	t.lock.Unlock()
+
+	for _, connection := range b.Connections {
+		connection.Innovation = t.connection(b, connection.Source.ID, connection.Target.ID)
+	}
+}
+
+// State returns a copy of the tracker, sorted so that it serializes deterministically.
+func (t *Innovations) State() InnovationsState {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	state := InnovationsState{
+		NextInnovation: t.nextInnovation,
+		NextNeuron:     t.nextNeuron,
+		Connections:    make([][3]int, 0, len(t.connections)),
+		Splits:         make([][2]int, 0, len(t.splits)),
+	}
+	for key, innovation := range t.connections {
+		state.Connections = append(state.Connections, [3]int{key[0], key[1], innovation})
+	}
+	for split, id := range t.splits {
+		state.Splits = append(state.Splits, [2]int{split, id})
+	}
+	sort.Slice(state.Connections, func(i, j int) bool { return state.Connections[i][2] < state.Connections[j][2] })
+	sort.Slice(state.Splits, func(i, j int) bool { return state.Splits[i][0] < state.Splits[j][0] })
+	return state
+}
+
+// RestoreInnovations rebuilds a tracker saved with State.
+func RestoreInnovations(state InnovationsState) *Innovations {
+	t := &Innovations{
+		connections:    make(map[[2]int]int, len(state.Connections)),
+		splits:         make(map[int]int, len(state.Splits)),
+		nextInnovation: state.NextInnovation,
+		nextNeuron:     state.NextNeuron,
+	}
+	for _, connection := range state.Connections {
+		t.connections[[2]int{connection[0], connection[1]}] = connection[2]
+	}
+	for _, split := range state.Splits {
+		t.splits[split[0]] = split[1]
+	}
+	return t
+}
+
+func (b *Brain) hasNeuron(id int) bool {
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			if neuron.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b *Brain) maxNeuronID() int {
+	id := 0
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			id = max(id, neuron.ID)
+		}
+	}
+	return id
+}
@@ -0,0 +1,164 @@
+package Brain
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Library holds saved brains grouped by species, the species being the name of the directory
+// holding the genome files (e.g. "predator" for library/predator/*), case included.
+type Library map[string][]*Brain
+
+// LoadLibrary reads every genome of a directory, zip or tar.gz archive. Files that do not lie
+// directly inside a species directory of the root, e.g. library/x.json or
+// library/predator/old/x.json, and files that are not genomes are skipped.
+func LoadLibrary(location string) (Library, error) {
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+
+	library := make(Library)
+	// name is relative to the root of the library
+	add := func(name string, r io.Reader) error {
+		dir, file := path.Split(path.Clean(filepath.ToSlash(name)))
+		species := strings.TrimSuffix(dir, "/")
+		if species == "" || species == ".." || strings.Contains(species, "/") || strings.HasPrefix(file, ".") {
+			return nil
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Join(location, name), err)
+		}
+		brain, err := UnmarshalGenome(data)
+		if err != nil {
+			fmt.Printf("WARNING: skipping %s: %v\n", filepath.Join(location, name), err)
+			return nil
+		}
+		library[species] = append(library[species], brain)
+		return nil
+	}
+
+	lower := strings.ToLower(location)
+	switch {
+	case info.IsDir():
+		err = loadDirectory(location, add)
+	case strings.HasSuffix(lower, ".zip"):
+		err = loadZip(location, add)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"), strings.HasSuffix(lower, ".tar"):
+		err = loadTar(location, add)
+	default:
+		err = fmt.Errorf("unsupported genome library %s, expected a directory, .zip or .tar.gz", location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return library, nil
+}
+
+// Compatible returns the brains of species that have the given number of inputs (bias
+// neuron excluded) and outputs.
+func (l Library) Compatible(species string, inputs, outputs int) []*Brain {
+	compatible := make([]*Brain, 0, len(l[species]))
+	for _, brain := range l[species] {
+		if len(brain.InputNeurons) == inputs+1 && len(brain.OutputNeurons) == outputs {
+			compatible = append(compatible, brain)
+		}
+	}
+	return compatible
+}
+
+// Species returns the species found in the library, sorted.
+func (l Library) Species() []string {
+	species := make([]string, 0, len(l))
+	for name := range l {
+		species = append(species, name)
+	}
+	sort.Strings(species)
+	return species
+}
+
+func loadDirectory(root string, add func(string, io.Reader) error) error {
+	return filepath.WalkDir(root, func(name string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return add(rel, file)
+	})
+}
+
+func loadZip(location string, add func(string, io.Reader) error) error {
+	archive, err := zip.OpenReader(location)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		file, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = add(entry.Name, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadTar(location string, add func(string, io.Reader) error) error {
+	file, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if !strings.HasSuffix(strings.ToLower(location), ".tar") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := add(header.Name, archive); err != nil {
+			return err
+		}
+	}
+}
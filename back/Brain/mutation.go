@@ -0,0 +1,333 @@
+package Brain
+
+import (
+	"Prey_Predator_MAS/config"
+	"fmt"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MutationOperator is one kind of mutation. Operators are registered by name and drawn by
+// Mutate in proportion to their rate, the name being the key of the rate in
+// config.MutationRate.Custom for operators registered outside of this package.
+type MutationOperator interface {
+	Name() string
+	// Applicable tells whether Apply can run on b, operators that cannot are left out of the draw
+	Applicable(b *Brain, ctx Context) bool
+	// Apply mutates b, drawing only from ctx.Rand so that runs stay reproducible
+	Apply(b *Brain, ctx Context)
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     []MutationOperator
+	// generation counts the registrations, the cached table is rebuilt after one
+	generation int
+)
+
+// tableCache holds the table of the last rates given to a Context without Mutations.
+var tableCache struct {
+	sync.Mutex
+	generation int
+	rates      config.MutationRate
+	table      *MutationTable
+}
+
+// The built-in operators, in the order of the historical draw.
+func init() {
+	RegisterMutation(NewMutation("none", nil, func(b *Brain, ctx Context) {}))
+	RegisterMutation(NewMutation("weight", hasConnections, func(b *Brain, ctx Context) { b.weightMutation(ctx.Config, ctx.Rand) }))
+	RegisterMutation(NewMutation("bias", nil, func(b *Brain, ctx Context) { b.biasMutation(ctx.Config, ctx.Rand) }))
+	RegisterMutation(NewMutation("newConnection", nil, (*Brain).newConnection))
+	RegisterMutation(NewMutation("delConnection", hasConnections, func(b *Brain, ctx Context) { b.delConnection(ctx.Rand) }))
+	RegisterMutation(NewMutation("newNeuron", func(b *Brain, ctx Context) bool {
+		return len(b.Connections) > 0 && len(b.HiddenNeurons) < ctx.Config.MaxNeuronNumber
+	}, (*Brain).newNeuron))
+	RegisterMutation(NewMutation("delNeuron", hasHiddenNeurons, func(b *Brain, ctx Context) { b.delNeuron(ctx.Rand) }))
+	RegisterMutation(NewMutation("activation", hasHiddenNeurons, func(b *Brain, ctx Context) { b.activationMutation(ctx.Rand) }))
+	RegisterMutation(NewMutation("newRecurrentConnection", func(b *Brain, ctx Context) bool {
+		return ctx.Config.RecurrentConnections
+	}, (*Brain).newRecurrentConnection))
+	RegisterMutation(NewMutation("weightReset", hasConnections, func(b *Brain, ctx Context) { b.weightReset(ctx.Config, ctx.Rand) }))
+	RegisterMutation(NewMutation("toggleConnection", hasConnections, func(b *Brain, ctx Context) { b.toggleConnection(ctx.Rand) }))
+	RegisterMutation(NewMutation("splitNeuron", func(b *Brain, ctx Context) bool {
+		if len(b.HiddenNeurons) >= ctx.Config.MaxNeuronNumber {
+			return false
+		}
+		for _, connection := range b.Connections {
+			if !connection.Disabled {
+				return true
+			}
+		}
+		return false
+	}, (*Brain).splitNeuron))
+}
+
+func hasConnections(b *Brain, ctx Context) bool { return len(b.Connections) > 0 }
+
+func hasHiddenNeurons(b *Brain, ctx Context) bool { return len(b.HiddenNeurons) > 0 }
+
+// RegisterMutation adds an operator to the registry, usually from an init function. The
+// registration order is the draw order. It panics if the name is already registered.
+func RegisterMutation(operator MutationOperator) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	for _, registered := range registry {
+		if registered.Name() == operator.Name() {
+			panic(fmt.Sprintf("Brain: mutation %q registered twice", operator.Name()))
+		}
+	}
+	registry = append(registry, operator)
+	generation++
+}
+
+// MutationOperators returns the names of the registered operators, in draw order.
+func MutationOperators() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	names := make([]string, len(registry))
+	for i, operator := range registry {
+		names[i] = operator.Name()
+	}
+	return names
+}
+
+type mutation struct {
+	name       string
+	applicable func(b *Brain, ctx Context) bool
+	apply      func(b *Brain, ctx Context)
+}
+
+// NewMutation makes an operator of two functions, applicable may be nil when the operator
+// always applies.
+func NewMutation(name string, applicable func(b *Brain, ctx Context) bool, apply func(b *Brain, ctx Context)) MutationOperator {
+	return &mutation{name: name, applicable: applicable, apply: apply}
+}
+
+func (m *mutation) Name() string { return m.name }
+
+func (m *mutation) Applicable(b *Brain, ctx Context) bool {
+	return m.applicable == nil || m.applicable(b, ctx)
+}
+
+func (m *mutation) Apply(b *Brain, ctx Context) { m.apply(b, ctx) }
+
+// MutationTable is the registry weighted by one set of rates, e.g. the rates of a species.
+type MutationTable struct {
+	operators []MutationOperator
+	rates     []int
+}
+
+// NewMutationTable gives every registered operator its rate in rates, operators without a rate
+// are never drawn. Rates of unknown operators are reported by the error, the table is usable
+// anyway.
+func NewMutationTable(rates map[string]int) (*MutationTable, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	t := &MutationTable{}
+	known := make(map[string]bool, len(registry))
+	for _, operator := range registry {
+		known[operator.Name()] = true
+		if rate := rates[operator.Name()]; rate > 0 {
+			t.operators = append(t.operators, operator)
+			t.rates = append(t.rates, rate)
+		}
+	}
+
+	var unknown []string
+	for name := range rates {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return t, fmt.Errorf("unknown mutation operators: %s", strings.Join(unknown, ", "))
+	}
+	return t, nil
+}
+
+// table returns ctx.Mutations, or a table of the rates of ctx.Config when it is nil. That table
+// is only rebuilt when the rates or the registry change.
+func (ctx Context) table() *MutationTable {
+	if ctx.Mutations != nil {
+		return ctx.Mutations
+	}
+
+	registryLock.RLock()
+	current := generation
+	registryLock.RUnlock()
+
+	tableCache.Lock()
+	defer tableCache.Unlock()
+	rates := ctx.Config.MutationRate
+	if tableCache.table == nil || tableCache.generation != current || !reflect.DeepEqual(tableCache.rates, rates) {
+		tableCache.table, _ = NewMutationTable(rates.Rates())
+		tableCache.generation = current
+		tableCache.rates = rates
+		tableCache.rates.Custom = maps.Clone(rates.Custom)
+	}
+	return tableCache.table
+}
+
+// choose draws one of the operators applicable to b, nil if none is. In the self-adaptive mode
+// the operators are weighted by the rates of b instead of the table ones.
+func (t *MutationTable) choose(b *Brain, ctx Context) MutationOperator {
+	if ctx.Config.SelfAdaptiveMutation {
+		return t.chooseAdaptive(b, ctx)
+	}
+
+	rates := make([]int, len(t.rates))
+	total := 0
+	for i, operator := range t.operators {
+		if operator.Applicable(b, ctx) {
+			rates[i] = t.rates[i]
+			total += rates[i]
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	n := ctx.Rand.Intn(total)
+	for i, rate := range rates {
+		if n < rate {
+			return t.operators[i]
+		}
+		n -= rate
+	}
+	return nil
+}
+
+func (t *MutationTable) chooseAdaptive(b *Brain, ctx Context) MutationOperator {
+	rates := b.mutationParams(ctx.Config, t).Rates
+	weights := make([]float64, len(t.operators))
+	total, last := 0.0, -1
+	for i, operator := range t.operators {
+		if rates[operator.Name()] > 0 && operator.Applicable(b, ctx) {
+			weights[i] = rates[operator.Name()]
+			total += weights[i]
+			last = i
+		}
+	}
+	if last < 0 {
+		return nil
+	}
+
+	x := ctx.Rand.Float64() * total
+	for i, weight := range weights {
+		if x < weight {
+			return t.operators[i]
+		}
+		x -= weight
+	}
+	// rounding errors
+	return t.operators[last]
+}
+
+// MutationRecord is a mutation a brain received since it was created or copied.
+type MutationRecord struct {
+	Operator string `json:"operator"`
+	// Inherited is set once the brain has passed the mutation on to an offspring
+	Inherited bool `json:"inherited,omitempty"`
+}
+
+// MutationCount is how often an operator fired and how many of these mutations were inherited.
+type MutationCount struct {
+	Operator  string `json:"operator"`
+	Fired     uint64 `json:"fired"`
+	Inherited uint64 `json:"inherited"`
+}
+
+// MutationStats counts the mutations of a population by operator. A mutation counts as
+// inherited the first time the brain that received it reproduces, see Inherit.
+type MutationStats struct {
+	lock      sync.Mutex
+	fired     map[string]uint64
+	inherited map[string]uint64
+}
+
+func NewMutationStats() *MutationStats {
+	return &MutationStats{
+		fired:     make(map[string]uint64),
+		inherited: make(map[string]uint64),
+	}
+}
+
+// fire counts a mutation applied to b and records it in b. It does nothing on nil stats.
+func (s *MutationStats) fire(b *Brain, operator string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.fired[operator]++
+	s.lock.Unlock()
+	b.mutations = append(b.mutations, MutationRecord{Operator: operator})
+}
+
+// Inherit is called when parent passes its brain on to an offspring, it counts the mutations
+// of parent that are inherited for the first time.
+func (s *MutationStats) Inherit(parent *Brain) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i := range parent.mutations {
+		if record := &parent.mutations[i]; !record.Inherited {
+			record.Inherited = true
+			s.inherited[record.Operator]++
+		}
+	}
+}
+
+// Counts returns the counters of every registered operator in draw order, followed by the
+// operators that fired but are no longer registered.
+func (s *MutationStats) Counts() []MutationCount {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	names := MutationOperators()
+	registered := make(map[string]bool, len(names))
+	for _, name := range names {
+		registered[name] = true
+	}
+	var others []string
+	for name := range s.fired {
+		if !registered[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+
+	counts := make([]MutationCount, 0, len(names)+len(others))
+	for _, name := range append(names, others...) {
+		counts = append(counts, MutationCount{Operator: name, Fired: s.fired[name], Inherited: s.inherited[name]})
+	}
+	return counts
+}
+
+// RestoreMutationStats rebuilds counters saved with Counts.
+func RestoreMutationStats(counts []MutationCount) *MutationStats {
+	s := NewMutationStats()
+	for _, count := range counts {
+		if count.Fired > 0 {
+			s.fired[count.Operator] = count.Fired
+		}
+		if count.Inherited > 0 {
+			s.inherited[count.Operator] = count.Inherited
+		}
+	}
+	return s
+}
+
+// Mutations returns a copy of the mutations recorded in b.
+func (b *Brain) Mutations() []MutationRecord {
+	return append([]MutationRecord(nil), b.mutations...)
+}
+
+// SetMutations replaces the mutations recorded in b, e.g. when loading a snapshot.
+func (b *Brain) SetMutations(records []MutationRecord) {
+	b.mutations = append([]MutationRecord(nil), records...)
+}
@@ -0,0 +1,155 @@
+package Brain
+
+import (
+	"Prey_Predator_MAS/config"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// TestMutationTableDraws checks that a table only draws the operators that have a rate and
+// apply to the brain, and draws all of them.
+func TestMutationTableDraws(t *testing.T) {
+	bare := func() *Brain {
+		return &Brain{InputNeurons: []*Neuron{{ID: 0}, {ID: 1}}, OutputNeurons: []*Neuron{{ID: 2, Depth: 1}}, finalDepth: 1}
+	}
+	wired := func() *Brain {
+		b, _ := chain()
+		return b
+	}
+
+	tests := []struct {
+		name         string
+		rates        map[string]int
+		selfAdaptive bool
+		brain        func() *Brain
+		want         []string
+	}{
+		{name: "zero rate", rates: map[string]int{"bias": 1, "weight": 0}, brain: wired, want: []string{"bias"}},
+		{name: "not applicable", rates: map[string]int{"bias": 1, "weight": 1, "delNeuron": 1}, brain: bare, want: []string{"bias"}},
+		{name: "applicable", rates: map[string]int{"bias": 1, "weight": 1, "delNeuron": 1}, brain: wired, want: []string{"bias", "delNeuron", "weight"}},
+		{name: "registered outside of the defaults", rates: map[string]int{"testBreakDepth": 1}, brain: bare, want: []string{"testBreakDepth"}},
+		{name: "self-adaptive", rates: map[string]int{"bias": 1, "weight": 2}, selfAdaptive: true, brain: wired, want: []string{"bias", "weight"}},
+		{name: "self-adaptive not applicable", rates: map[string]int{"bias": 1, "weight": 2}, selfAdaptive: true, brain: bare, want: []string{"bias"}},
+		{name: "nothing applies", rates: map[string]int{"weight": 1, "delNeuron": 1}, brain: bare},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.SelfAdaptiveMutation = tt.selfAdaptive
+			table, err := NewMutationTable(tt.rates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := Context{Config: &cfg, Rand: rand.New(rand.NewSource(6)), Mutations: table}
+			brain := tt.brain()
+
+			var got []string
+			for i := 0; i < 500; i++ {
+				if operator := table.choose(brain, ctx); operator != nil && !slices.Contains(got, operator.Name()) {
+					got = append(got, operator.Name())
+				}
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("drew %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNewMutationTableUnknown checks that unknown operators are reported and that the table
+// draws the known ones anyway.
+func TestNewMutationTableUnknown(t *testing.T) {
+	table, err := NewMutationTable(map[string]int{"bias": 1, "notAnOperator": 1})
+	if err == nil {
+		t.Error("unknown operator accepted")
+	}
+	cfg := config.GetDefaultConfig()
+	b, _ := chain()
+	if operator := table.choose(b, Context{Config: &cfg, Rand: rand.New(rand.NewSource(7))}); operator == nil || operator.Name() != "bias" {
+		t.Errorf("drew %v, want bias", operator)
+	}
+}
+
+// TestContextTableCache checks that the table of a context without one is reused until the
+// rates or the registry change.
+func TestContextTableCache(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	ctx := Context{Config: &cfg}
+	first := ctx.table()
+
+	steps := []struct {
+		name    string
+		change  func()
+		rebuilt bool
+	}{
+		{name: "same rates", change: func() {}},
+		{name: "equal copy of the config", change: func() {
+			copied := cfg
+			ctx.Config = &copied
+		}},
+		{name: "rate changed", change: func() { ctx.Config.MutationRate.BiasMutationRate++ }, rebuilt: true},
+		{name: "custom rate added", change: func() { ctx.Config.MutationRate.Custom = map[string]int{"testBreakDepth": 1} }, rebuilt: true},
+		{name: "custom rate changed in place", change: func() { ctx.Config.MutationRate.Custom["testBreakDepth"] = 2 }, rebuilt: true},
+		{name: "operator registered", change: func() {
+			// a new name on every run, e.g. with -count
+			RegisterMutation(NewMutation(fmt.Sprintf("testCache%d", len(MutationOperators())), nil, func(b *Brain, ctx Context) {}))
+		}, rebuilt: true},
+		{name: "unchanged again", change: func() {}},
+	}
+
+	previous := first
+	for _, step := range steps {
+		step.change()
+		table := ctx.table()
+		if rebuilt := table != previous; rebuilt != step.rebuilt {
+			t.Errorf("%s: rebuilt %v, want %v", step.name, rebuilt, step.rebuilt)
+		}
+		previous = table
+	}
+}
+
+// TestMutationStats checks that mutations are counted when they fire and the first time their
+// brain reproduces.
+func TestMutationStats(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	table, err := NewMutationTable(map[string]int{"bias": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := NewMutationStats()
+	ctx := Context{Config: &cfg, Rand: rand.New(rand.NewSource(8)), Mutations: table, Stats: stats}
+
+	parent, _ := chain()
+	for i := 0; i < 3; i++ {
+		if err := parent.Mutate(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats.Inherit(parent)
+	stats.Inherit(parent)
+	child, err := parent.Copy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := child.Mutate(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := RestoreMutationStats(stats.Counts()).Counts()
+	for _, count := range counts {
+		want := MutationCount{Operator: count.Operator}
+		if count.Operator == "bias" {
+			want = MutationCount{Operator: "bias", Fired: 4, Inherited: 3}
+		}
+		if count != want {
+			t.Errorf("count %+v, want %+v", count, want)
+		}
+	}
+	if len(counts) != len(MutationOperators()) {
+		t.Errorf("%d counts for %d operators", len(counts), len(MutationOperators()))
+	}
+}
@@ -0,0 +1,317 @@
+package Brain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors returned by Validate. Neurons are identified by their ID, -1 standing for a nil
+// neuron or one that is not part of the brain.
+
+// ForeignEndpointError is a connection whose source or target is nil or not a neuron of the brain.
+type ForeignEndpointError struct {
+	Connection     int
+	Source, Target int
+}
+
+func (e *ForeignEndpointError) Error() string {
+	return fmt.Sprintf("connection %d (%d -> %d) has an endpoint outside of the brain", e.Connection, e.Source, e.Target)
+}
+
+// DuplicateNeuronError is a neuron ID used twice, or a neuron listed twice.
+type DuplicateNeuronError struct {
+	ID int
+}
+
+func (e *DuplicateNeuronError) Error() string {
+	return fmt.Sprintf("neuron %d is listed twice", e.ID)
+}
+
+// DuplicateConnectionError is a connection listed twice, or two connections between the same
+// neurons.
+type DuplicateConnectionError struct {
+	Source, Target int
+}
+
+func (e *DuplicateConnectionError) Error() string {
+	return fmt.Sprintf("connection %d -> %d is listed twice", e.Source, e.Target)
+}
+
+// AdjacencyError is a connection of Brain.Connections missing from the Connections of its
+// source neuron, or the other way around.
+type AdjacencyError struct {
+	Source, Target int
+	Reason         string
+}
+
+func (e *AdjacencyError) Error() string {
+	return fmt.Sprintf("connection %d -> %d %s", e.Source, e.Target, e.Reason)
+}
+
+// CycleError is a loop of non-recurrent connections, Neurons lists it from its first neuron
+// back to it.
+type CycleError struct {
+	Neurons []int
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("non-recurrent connections form the cycle %v", e.Neurons)
+}
+
+// DepthError is a non-recurrent connection that does not go to a deeper neuron, or a
+// connection into an input neuron.
+type DepthError struct {
+	Source, Target           int
+	SourceDepth, TargetDepth int
+}
+
+func (e *DepthError) Error() string {
+	return fmt.Sprintf("connection %d -> %d goes from depth %d to depth %d", e.Source, e.Target, e.SourceDepth, e.TargetDepth)
+}
+
+// OrderError is a neuron out of the evaluation order: a nil neuron, an input that is not at
+// depth 0, a hidden neuron shallower than the previous one or an output not deeper than every
+// hidden neuron.
+type OrderError struct {
+	Neuron int
+	Depth  int
+	Reason string
+}
+
+func (e *OrderError) Error() string {
+	return fmt.Sprintf("neuron %d at depth %d %s", e.Neuron, e.Depth, e.Reason)
+}
+
+// ErrNoMutation is the error of a mutation for which no operator applies, e.g. when every
+// applicable operator has a zero rate.
+var ErrNoMutation = errors.New("no mutation applies")
+
+// MutationError is a mutation that failed: no operator was chosen (Err is ErrNoMutation and
+// Operator is empty), or, in the debug mode, the brain fails Validate after the mutation.
+type MutationError struct {
+	Operator string
+	Err      error
+}
+
+func (e *MutationError) Error() string {
+	if e.Operator == "" {
+		return fmt.Sprintf("mutation failed: %v", e.Err)
+	}
+	return fmt.Sprintf("brain invalid after %s mutation: %v", e.Operator, e.Err)
+}
+
+func (e *MutationError) Unwrap() error { return e.Err }
+
+// Validate checks the structural invariants TakeDecision relies on: every connection links two
+// neurons of the brain and is listed once, by Connections and by its source neuron; the
+// non-recurrent connections form no cycle and go to deeper neurons; inputs are at depth 0,
+// hidden neurons are sorted by depth and outputs are deeper than them. Every problem found is
+// reported, use errors.As to look for a given one.
+func (b *Brain) Validate() error {
+	var errs []error
+
+	neurons := make(map[*Neuron]bool)
+	ids := make(map[int]bool)
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			if neuron == nil {
+				errs = append(errs, &OrderError{Neuron: -1, Reason: "is nil"})
+				continue
+			}
+			if neurons[neuron] || ids[neuron.ID] {
+				errs = append(errs, &DuplicateNeuronError{ID: neuron.ID})
+			}
+			neurons[neuron] = true
+			ids[neuron.ID] = true
+		}
+	}
+	id := func(neuron *Neuron) int {
+		if neuron == nil || !neurons[neuron] {
+			return -1
+		}
+		return neuron.ID
+	}
+
+	listed := make(map[*Connection]bool, len(b.Connections))
+	pairs := make(map[[2]*Neuron]bool, len(b.Connections))
+	valid := make([]*Connection, 0, len(b.Connections))
+	for i, connection := range b.Connections {
+		if connection == nil || id(connection.Source) < 0 || id(connection.Target) < 0 {
+			foreign := &ForeignEndpointError{Connection: i, Source: -1, Target: -1}
+			if connection != nil {
+				foreign.Source, foreign.Target = id(connection.Source), id(connection.Target)
+			}
+			errs = append(errs, foreign)
+			continue
+		}
+		pair := [2]*Neuron{connection.Source, connection.Target}
+		if listed[connection] || pairs[pair] {
+			errs = append(errs, &DuplicateConnectionError{Source: connection.Source.ID, Target: connection.Target.ID})
+			continue
+		}
+		listed[connection] = true
+		pairs[pair] = true
+		valid = append(valid, connection)
+	}
+
+	// adjacency: the outgoing connections of every neuron are exactly those of the brain
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			if neuron == nil {
+				continue
+			}
+			seen := make(map[*Connection]bool, len(neuron.Connections))
+			for _, connection := range neuron.Connections {
+				switch {
+				case connection == nil:
+					errs = append(errs, &AdjacencyError{Source: neuron.ID, Target: -1, Reason: "is nil in the list of its source"})
+				case connection.Source != neuron:
+					errs = append(errs, &AdjacencyError{Source: id(connection.Source), Target: id(connection.Target), Reason: fmt.Sprintf("is listed by neuron %d", neuron.ID)})
+				case !listed[connection]:
+					errs = append(errs, &AdjacencyError{Source: neuron.ID, Target: id(connection.Target), Reason: "is listed by its source but not by the brain"})
+				case seen[connection]:
+					errs = append(errs, &AdjacencyError{Source: neuron.ID, Target: id(connection.Target), Reason: "is listed twice by its source"})
+				}
+				seen[connection] = true
+			}
+		}
+	}
+	outgoing := make(map[*Connection]bool, len(valid))
+	for _, connection := range valid {
+		for _, sourceConnection := range connection.Source.Connections {
+			if sourceConnection == connection {
+				outgoing[connection] = true
+			}
+		}
+		if !outgoing[connection] {
+			errs = append(errs, &AdjacencyError{Source: connection.Source.ID, Target: connection.Target.ID, Reason: "is missing from the list of its source"})
+		}
+	}
+
+	if cycle := b.findCycle(valid); cycle != nil {
+		errs = append(errs, &CycleError{Neurons: cycle})
+	}
+
+	for _, connection := range valid {
+		source, target := connection.Source, connection.Target
+		if containsNeuron(b.InputNeurons, target) || (!connection.Recurrent && source.Depth >= target.Depth) {
+			errs = append(errs, &DepthError{Source: source.ID, Target: target.ID, SourceDepth: source.Depth, TargetDepth: target.Depth})
+		}
+	}
+
+	for _, neuron := range b.InputNeurons {
+		if neuron != nil && neuron.Depth != 0 {
+			errs = append(errs, &OrderError{Neuron: neuron.ID, Depth: neuron.Depth, Reason: "is an input neuron"})
+		}
+	}
+	deepest := 0
+	for i, neuron := range b.HiddenNeurons {
+		if neuron == nil {
+			continue
+		}
+		if i > 0 && b.HiddenNeurons[i-1] != nil && neuron.Depth < b.HiddenNeurons[i-1].Depth {
+			errs = append(errs, &OrderError{Neuron: neuron.ID, Depth: neuron.Depth, Reason: "comes after a deeper hidden neuron"})
+		}
+		if neuron.Depth < 1 {
+			errs = append(errs, &OrderError{Neuron: neuron.ID, Depth: neuron.Depth, Reason: "is a hidden neuron"})
+		}
+		deepest = max(deepest, neuron.Depth)
+	}
+	for _, neuron := range b.OutputNeurons {
+		if neuron != nil && neuron.Depth <= deepest {
+			errs = append(errs, &OrderError{Neuron: neuron.ID, Depth: neuron.Depth, Reason: fmt.Sprintf("is an output neuron, hidden neurons reach depth %d", deepest)})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// findCycle returns the IDs of a cycle of non-recurrent connections, nil if there is none.
+func (b *Brain) findCycle(connections []*Connection) []int {
+	next := make(map[*Neuron][]*Neuron)
+	for _, connection := range connections {
+		if !connection.Recurrent {
+			next[connection.Source] = append(next[connection.Source], connection.Target)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*Neuron]int)
+	var path []*Neuron
+	var visit func(neuron *Neuron) []int
+	visit = func(neuron *Neuron) []int {
+		state[neuron] = visiting
+		path = append(path, neuron)
+		for _, target := range next[neuron] {
+			switch state[target] {
+			case visiting:
+				var cycle []int
+				for i := len(path) - 1; i >= 0; i-- {
+					if path[i] == target {
+						for _, n := range path[i:] {
+							cycle = append(cycle, n.ID)
+						}
+						break
+					}
+				}
+				return append(cycle, target.ID)
+			case unvisited:
+				if cycle := visit(target); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[neuron] = done
+		return nil
+	}
+
+	// start from the neurons in brain order so that the reported cycle is deterministic
+	sources := make([]*Neuron, 0, len(next))
+	for _, layer := range [][]*Neuron{b.InputNeurons, b.HiddenNeurons, b.OutputNeurons} {
+		for _, neuron := range layer {
+			if neuron != nil && len(next[neuron]) > 0 {
+				sources = append(sources, neuron)
+			}
+		}
+	}
+	for _, neuron := range sources {
+		if state[neuron] == unvisited {
+			if cycle := visit(neuron); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// endpointID is the ID of an endpoint of a connection being copied, -1 if it has no copy.
+func endpointID(neuron, copied *Neuron) int {
+	if neuron == nil || copied == nil {
+		return -1
+	}
+	return neuron.ID
+}
+
+// connectionIndex is the index of connection in b.Connections, -1 if it is not listed.
+func (b *Brain) connectionIndex(connection *Connection) int {
+	for i, c := range b.Connections {
+		if c == connection {
+			return i
+		}
+	}
+	return -1
+}
+
+func containsNeuron(layer []*Neuron, neuron *Neuron) bool {
+	for _, n := range layer {
+		if n == neuron {
+			return true
+		}
+	}
+	return false
+}
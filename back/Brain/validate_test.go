@@ -0,0 +1,187 @@
+package Brain
+
+import (
+	"Prey_Predator_MAS/config"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// chain is a valid brain of one input, the bias neuron, two hidden neurons and one output,
+// wired input -> hidden -> hidden -> output. Its neurons are returned by ID.
+func chain() (*Brain, map[int]*Neuron) {
+	neurons := map[int]*Neuron{
+		0: {ID: 0},
+		1: {ID: 1},
+		2: {ID: 2, Depth: 3},
+		3: {ID: 3, Depth: 1},
+		4: {ID: 4, Depth: 2},
+	}
+	b := &Brain{
+		InputNeurons:  []*Neuron{neurons[0], neurons[1]},
+		HiddenNeurons: []*Neuron{neurons[3], neurons[4]},
+		OutputNeurons: []*Neuron{neurons[2]},
+		finalDepth:    3,
+	}
+	connect(b, neurons[0], neurons[3], false)
+	connect(b, neurons[3], neurons[4], false)
+	connect(b, neurons[4], neurons[2], false)
+	return b, neurons
+}
+
+// connect adds a connection to the brain and to its source.
+func connect(b *Brain, source, target *Neuron, recurrent bool) *Connection {
+	connection := &Connection{Source: source, Target: target, Weight: 1, Recurrent: recurrent}
+	b.Connections = append(b.Connections, connection)
+	source.Connections = append(source.Connections, connection)
+	return connection
+}
+
+// TestValidate checks the error Validate reports for every kind of broken brain.
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		breaks func(b *Brain, n map[int]*Neuron)
+		want   error
+	}{
+		{name: "valid", breaks: func(b *Brain, n map[int]*Neuron) {}},
+		{name: "recurrent loop", breaks: func(b *Brain, n map[int]*Neuron) {
+			connect(b, n[2], n[3], true)
+			connect(b, n[4], n[4], true)
+		}},
+		{name: "disabled connection", breaks: func(b *Brain, n map[int]*Neuron) { b.Connections[1].Disabled = true }},
+		{name: "nil connection", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.Connections = append(b.Connections, nil)
+		}, want: &ForeignEndpointError{Connection: 3, Source: -1, Target: -1}},
+		{name: "foreign target", breaks: func(b *Brain, n map[int]*Neuron) {
+			connect(b, n[0], &Neuron{ID: 9, Depth: 2}, false)
+		}, want: &ForeignEndpointError{Connection: 3, Source: 0, Target: -1}},
+		{name: "duplicate neuron ID", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.HiddenNeurons = append(b.HiddenNeurons, &Neuron{ID: 3, Depth: 2})
+		}, want: &DuplicateNeuronError{ID: 3}},
+		{name: "neuron listed twice", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.HiddenNeurons = append(b.HiddenNeurons, n[4])
+		}, want: &DuplicateNeuronError{ID: 4}},
+		{name: "duplicate connection", breaks: func(b *Brain, n map[int]*Neuron) {
+			connect(b, n[0], n[3], false)
+		}, want: &DuplicateConnectionError{Source: 0, Target: 3}},
+		{name: "connection listed twice", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.Connections = append(b.Connections, b.Connections[0])
+		}, want: &DuplicateConnectionError{Source: 0, Target: 3}},
+		{name: "missing from its source", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.Connections = append(b.Connections, &Connection{Source: n[0], Target: n[2]})
+		}, want: &AdjacencyError{Source: 0, Target: 2, Reason: "is missing from the list of its source"}},
+		{name: "missing from the brain", breaks: func(b *Brain, n map[int]*Neuron) {
+			n[0].Connections = append(n[0].Connections, &Connection{Source: n[0], Target: n[2]})
+		}, want: &AdjacencyError{Source: 0, Target: 2, Reason: "is listed by its source but not by the brain"}},
+		{name: "listed by another neuron", breaks: func(b *Brain, n map[int]*Neuron) {
+			n[1].Connections = append(n[1].Connections, b.Connections[0])
+		}, want: &AdjacencyError{Source: 0, Target: 3, Reason: "is listed by neuron 1"}},
+		{name: "cycle", breaks: func(b *Brain, n map[int]*Neuron) {
+			connect(b, n[4], n[3], false)
+		}, want: &CycleError{Neurons: []int{3, 4, 3}}},
+		{name: "connection to the same depth", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.HiddenNeurons = append(b.HiddenNeurons, &Neuron{ID: 5, Depth: 2})
+			connect(b, n[4], b.HiddenNeurons[2], false)
+		}, want: &DepthError{Source: 4, Target: 5, SourceDepth: 2, TargetDepth: 2}},
+		{name: "recurrent connection into an input", breaks: func(b *Brain, n map[int]*Neuron) {
+			connect(b, n[2], n[0], true)
+		}, want: &DepthError{Source: 2, Target: 0, SourceDepth: 3, TargetDepth: 0}},
+		{name: "nil neuron", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.HiddenNeurons = append(b.HiddenNeurons, nil)
+		}, want: &OrderError{Neuron: -1, Reason: "is nil"}},
+		{name: "deep input", breaks: func(b *Brain, n map[int]*Neuron) { n[1].Depth = 1 }, want: &OrderError{Neuron: 1, Depth: 1, Reason: "is an input neuron"}},
+		{name: "unsorted hidden neurons", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.HiddenNeurons = append(b.HiddenNeurons, &Neuron{ID: 5, Depth: 1})
+		}, want: &OrderError{Neuron: 5, Depth: 1, Reason: "comes after a deeper hidden neuron"}},
+		{name: "shallow output", breaks: func(b *Brain, n map[int]*Neuron) {
+			b.HiddenNeurons = append(b.HiddenNeurons, &Neuron{ID: 5, Depth: 3})
+		}, want: &OrderError{Neuron: 2, Depth: 3, Reason: "is an output neuron, hidden neurons reach depth 3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, neurons := chain()
+			tt.breaks(b, neurons)
+			err := b.Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("valid brain rejected: %v", err)
+				}
+				return
+			}
+			got := reflect.New(reflect.TypeOf(tt.want))
+			if !errors.As(err, got.Interface()) {
+				t.Fatalf("want a %T, got %v", tt.want, err)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got.Elem().Interface())
+			}
+		})
+	}
+}
+
+func init() {
+	// testBreakDepth is only drawn by the tables of the tests that name it
+	RegisterMutation(NewMutation("testBreakDepth", nil, func(b *Brain, ctx Context) {
+		b.OutputNeurons[0].Depth = 0
+	}))
+}
+
+// TestMutateErrors checks the MutationError of a mutation without operator and of one that
+// breaks the brain in the debug mode.
+func TestMutateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rates map[string]int
+		debug bool
+		// operator is the one reported by the MutationError, empty when there is no error or
+		// no operator applies
+		operator   string
+		noMutation bool
+	}{
+		{name: "valid mutation", rates: map[string]int{"newConnection": 1}, debug: true},
+		{name: "no operator", rates: map[string]int{}, noMutation: true},
+		{name: "no applicable operator", rates: map[string]int{"delNeuron": 1, "weight": 1}, noMutation: true},
+		{name: "broken brain", rates: map[string]int{"testBreakDepth": 1}, debug: true, operator: "testBreakDepth"},
+		{name: "broken brain without debug", rates: map[string]int{"testBreakDepth": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.DebugBrains = tt.debug
+			table, err := NewMutationTable(tt.rates)
+			if err != nil {
+				t.Fatal(err)
+			}
+			brain := &Brain{
+				InputNeurons:  []*Neuron{{ID: 0}, {ID: 1}},
+				OutputNeurons: []*Neuron{{ID: 2, Depth: 1}},
+				finalDepth:    1,
+			}
+			err = brain.Mutate(Context{Config: &cfg, Rand: rand.New(rand.NewSource(1)), Mutations: table})
+			if tt.operator == "" && !tt.noMutation {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var mutationErr *MutationError
+			if !errors.As(err, &mutationErr) {
+				t.Fatalf("want a *MutationError, got %v", err)
+			}
+			if mutationErr.Operator != tt.operator {
+				t.Errorf("operator %q, want %q", mutationErr.Operator, tt.operator)
+			}
+			var orderErr *OrderError
+			if tt.noMutation && !errors.Is(err, ErrNoMutation) {
+				t.Errorf("want ErrNoMutation, got %v", err)
+			} else if !tt.noMutation && !errors.As(err, &orderErr) {
+				t.Errorf("want an *OrderError, got %v", err)
+			}
+		})
+	}
+}
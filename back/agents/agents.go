@@ -3,25 +3,34 @@ package agents
 import (
 	"Prey_Predator_MAS/Brain"
 	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/rng"
+	"Prey_Predator_MAS/terrain"
+	"Prey_Predator_MAS/topology"
 	"fmt"
 	"math"
-	"math/rand"
+	"slices"
 	"sync"
 
 	"github.com/quartercastle/vector"
 )
 
 type Agent struct {
-	ID          uint32        `json:"id"`
-	Position    vector.Vector `json:"pos"`
-	Color       string        `json:"color"`
-	Velocity    vector.Vector `json:"-"`
-	Perceipt    Perceipt      `json:"-"`
-	RaysValues  []float64     `json:"-"`
-	changedCell bool          `json:"-"`
-	Brain       *Brain.Brain  `json:"-"`
-	Speed       float64
-	Rotation    float64
+	ID         uint32        `json:"id"`
+	Position   vector.Vector `json:"pos"`
+	Velocity   vector.Vector `json:"-"`
+	Perceipt   Perceipt      `json:"-"`
+	RaysValues []float64     `json:"-"`
+	Channels   []float64     `json:"-"` // ray distances by channel, see RayGenerator.castRays
+	Inputs     []float64     `json:"-"` // brain inputs, filled by Sensors.Sense
+	Brain      *Brain.Brain  `json:"-"`
+	Speed      float64
+	Rotation   float64
+	// Attack and Reproduce are the decisions of the attack and reproduce outputs, true when the
+	// output is not negative or the brain has no such output
+	Attack    bool
+	Reproduce bool
+	// Signal is the value of the signal output, in [-1, 1]
+	Signal float64
 
 	LifePoints   int
 	Energy       int
@@ -31,14 +40,26 @@ type Agent struct {
 	Regen bool
 
 	Generation int
+	SpeciesID  uint32 `json:"speciesId"`
 
-	lock sync.Mutex
+	// Species holds the stats, senses and diet the agent shares with its species
+	Species *config.Species `json:"-"`
+
+	// Rng is the agent's own random stream, offspring streams are split from it
+	Rng *rng.Rand `json:"-"`
+
+	// nearest holds the ID of the agent each ray reports in RaysValues, ties go to the lowest
+	nearest []uint32
+	cfg     *config.Config
+	lock    sync.Mutex
 }
 
 type AgentViewModel struct {
 	ID         uint32                `json:"id"`
 	Position   vector.Vector         `json:"pos"`
 	Color      string                `json:"color"`
+	Species    string                `json:"species"`
+	SpeciesID  uint32                `json:"speciesId"`
 	Velocity   *vector.Vector        `json:"vel,omitempty"`
 	RaysValues *[]float64            `json:"raysValues,omitempty"`
 	Brain      *Brain.BrainViewModel `json:"brain,omitempty"`
@@ -48,35 +69,39 @@ type AgentViewModel struct {
 	Reproduction int `json:"reproduction"`
 	Digestion    int `json:"digestion"`
 
-	Generation int `json:"generation"`
+	Generation int     `json:"generation"`
+	Signal     float64 `json:"signal,omitempty"`
 }
 
+// NewAgentViewModel copies what the clients draw of agent, the brain too when it is selected.
+// It reads the agent and its brain, so it must not run during a tick, see
+// environment.Environment.AgentViewModels.
 func NewAgentViewModel(agent *Agent, isSelected bool) *AgentViewModel {
+	velocity := vector.Vector{agent.Velocity[0], agent.Velocity[1]}
 	vm := &AgentViewModel{
-		ID:       agent.ID,
-		Position: agent.Position,
-		Color:    agent.Color,
-		Velocity: &agent.Velocity,
+		ID:        agent.ID,
+		Position:  vector.Vector{agent.Position[0], agent.Position[1]},
+		Color:     agent.Species.Color,
+		Species:   agent.Species.Name,
+		SpeciesID: agent.SpeciesID,
+		Velocity:  &velocity,
+		Signal:    agent.Signal,
 	}
 
 	if isSelected {
 
-		vm.RaysValues = &agent.RaysValues
-		vm.Brain = Brain.NewBrainViewModel(agent.Brain)
+		rays := slices.Clone(agent.RaysValues)
+		vm.RaysValues = &rays
+		vm.Brain = Brain.NewBrainViewModel(agent.Brain, agent.cfg.InputLabels())
 
-		if agent.Color == "Red" {
-			vm.LifePoints = (agent.LifePoints * 100) / config.PREDATOR_LIFE_POINTS
-			vm.Reproduction = (agent.Reproduction * 100) / config.MAX_REPRODUCTION_PREDATOR
-		} else {
-			vm.LifePoints = (agent.LifePoints * 100) / config.PREY_LIFE_POINTS
-			vm.Reproduction = (agent.Reproduction * 100) / config.MAX_REPRODUCTION_PREY
-		}
+		vm.LifePoints = (agent.LifePoints * 100) / agent.Species.LifePoints
+		vm.Reproduction = (agent.Reproduction * 100) / agent.Species.MaxReproduction
 
 		if vm.Reproduction > 100 {
 			vm.Reproduction = 100
 		}
 
-		vm.Energy = (agent.Energy * 100) / config.MAX_ENERGY
+		vm.Energy = (agent.Energy * 100) / agent.cfg.MaxEnergy
 		vm.Digestion = agent.Digestion
 
 		vm.Generation = agent.Generation
@@ -85,33 +110,41 @@ func NewAgentViewModel(agent *Agent, isSelected bool) *AgentViewModel {
 	return vm
 }
 
-func GetGridCell(x, y float64) (uint32, uint32) {
-	cellSize := config.GetDefaultConfig().CellSize
-	return uint32(x / float64(cellSize)), uint32(y / float64(cellSize))
-}
-
-func NewAgent(ID uint32, x, y float64, color string, perceipt Perceipt, brain *Brain.Brain, lifePoint int, generation int) *Agent {
+func NewAgent(ID uint32, x, y float64, species *config.Species, perceipt Perceipt, brain *Brain.Brain, lifePoint int, generation int, cfg *config.Config, r *rng.Rand) *Agent {
 	// random vector of length 1
-	vel := vector.Vector{rand.Float64()*2 - 1, rand.Float64()*2 - 1}
+	vel := vector.Vector{r.Float64()*2 - 1, r.Float64()*2 - 1}
 	return &Agent{
 		ID:         ID,
 		Position:   vector.Vector{x, y},
-		Color:      color,
+		Species:    species,
 		Perceipt:   perceipt,
-		RaysValues: make([]float64, config.GetDefaultConfig().RayNumber),
+		RaysValues: make([]float64, cfg.RayNumber),
+		nearest:    make([]uint32, cfg.RayNumber),
+		Channels:   make([]float64, cfg.RayNumber*len(cfg.Channels())),
+		Inputs:     make([]float64, cfg.InputCount()),
 		Brain:      brain,
 
 		LifePoints:   lifePoint,
-		Energy:       config.MAX_ENERGY - rand.Intn(50),
-		Reproduction: rand.Intn(50),
+		Energy:       cfg.MaxEnergy - r.Intn(50),
+		Reproduction: r.Intn(50),
 		Velocity:     vel,
+		Attack:       true,
+		Reproduce:    true,
 
 		Generation: generation,
+
+		Rng: r,
+		cfg: cfg,
 	}
 }
 
-func (a *Agent) Move() (oldPosition vector.Vector) {
-	speed := a.Speed * float64(config.GetDefaultConfig().MaxSpeed)
+// Move advances the agent along its velocity, world deciding what happens at the edges. Water
+// slows the agent down, and it turns back instead of entering a cell of ground it cannot stand on.
+func (a *Agent) Move(world topology.Topology, ground *terrain.Map) (oldPosition vector.Vector) {
+	speed := a.Speed * float64(a.cfg.MaxSpeed)
+	if ground.At(a.Position[0], a.Position[1]) == terrain.WATER {
+		speed *= a.cfg.Terrain.WaterSpeed
+	}
 	// random angle between 0 and 360 degrees
 	rotation := a.Rotation * 360 * 2 * 3.141592653589793
 
@@ -123,14 +156,14 @@ func (a *Agent) Move() (oldPosition vector.Vector) {
 	a.Velocity = a.Velocity.Scale(speed)
 
 	oldPosition = a.Position.Clone()
-	a.Position = a.Position.Add(a.Velocity)
-	if !a.validatePosition() {
-		a.Position[0] = a.WrapAround(a.Position[0], float64(config.GetDefaultConfig().Width-1))
-		a.Position[1] = a.WrapAround(a.Position[1], float64(config.GetDefaultConfig().Height-1))
+	a.Position, a.Velocity = world.Move(a.Position, a.Velocity)
+	// an agent already stuck in such a cell, e.g. restored with another map, may walk out of it
+	hunter := a.Species.Hunts()
+	if ground.Blocks(a.Position[0], a.Position[1], hunter) && !ground.Blocks(oldPosition[0], oldPosition[1], hunter) {
+		a.Position = oldPosition.Clone()
+		a.Velocity = a.Velocity.Scale(-1)
 	}
 
-	a.CheckCellChange(oldPosition)
-
 	if math.IsNaN(a.Position[0]) {
 		fmt.Printf("issue")
 	}
@@ -138,33 +171,15 @@ func (a *Agent) Move() (oldPosition vector.Vector) {
 	return oldPosition
 }
 
-func (a *Agent) validatePosition() bool {
-	config := config.GetDefaultConfig()
-	return a.Position.X() > 0 && a.Position.X() < float64(config.Width-1) &&
-		a.Position.Y() > 0 && a.Position.Y() < float64(config.Width-1)
-}
-
-func (a *Agent) CheckCellChange(oldPosition vector.Vector) {
-	cellSize := float64(config.GetDefaultConfig().CellSize)
-	if (a.Position.X()/cellSize != oldPosition.X()/cellSize) ||
-		(a.Position.Y()/cellSize != oldPosition.Y()/cellSize) {
-		a.changedCell = true
-	}
-}
-
-func (a *Agent) ChangedCell() bool {
-	return a.changedCell
-}
-
 func (a *Agent) ApplyStatsUpdate() (energyLevel int, reproductionLevel int) {
-	a.Energy += -(config.ENERGY_LOSS_MULTIPLIER_SPEED*int(a.Speed) + 1)
-	if a.Color == "Green" {
-		a.Reproduction += config.PREY_REPRODUCTION_GAIN
-		if a.Reproduction > config.MAX_REPRODUCTION_PREY {
-			a.Reproduction = config.MAX_REPRODUCTION_PREY
+	a.Energy += -(a.cfg.EnergyLossMultiplierSpeed*int(a.Speed) + 1)
+	if a.Species.ReproductionGain > 0 {
+		a.Reproduction += a.Species.ReproductionGain
+		if a.Reproduction > a.Species.MaxReproduction {
+			a.Reproduction = a.Species.MaxReproduction
 		}
 	}
-	if a.Color == "Red" && a.Digestion > 0 {
+	if a.Digestion > 0 {
 		a.Digestion--
 	}
 	return a.Energy, a.Reproduction
@@ -178,9 +193,3 @@ func (a *Agent) ApplyDamage(damage int) bool {
 	return killed
 
 }
-func (a *Agent) WrapAround(value, max float64) float64 {
-	if value < 0 {
-		return max + math.Mod(value, max)
-	}
-	return math.Mod(value, max) + 1
-}
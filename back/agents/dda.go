@@ -0,0 +1,147 @@
+package agents
+
+import (
+	"Prey_Predator_MAS/terrain"
+	"Prey_Predator_MAS/topology"
+	"Prey_Predator_MAS/vegetation"
+	"math"
+
+	"github.com/quartercastle/vector"
+)
+
+// DDAPerceipt walks every ray through the grid cells it crosses, Amanatides-Woo style, instead
+// of testing every agent of the field of view against every ray. A ray stops as soon as no agent
+// further along can be nearer than what it already hit. It sees exactly what PreyPerceipt and
+// PredatorPerceipt see.
+type DDAPerceipt struct {
+	RayGenerator
+}
+
+// NewDDAPerceipt returns the grid traversal perception of a species, channels being
+// config.Config.Channels.
+func NewDDAPerceipt(rayNumber, rayLength int, rayAngle float64, channels []string, world topology.Topology, ground *terrain.Map, food *vegetation.Field) Perceipt {
+	return &DDAPerceipt{
+		RayGenerator: newRayGenerator(rayNumber, rayLength, rayAngle, -1, channels, world, ground, food),
+	}
+}
+
+func (p *DDAPerceipt) Perceive(agent *Agent, grid GridAgentProvider) {
+	rays, _ := p.RayGenerator.generateRays(agent)
+	clear(agent.RaysValues)
+	clear(agent.Channels)
+	obstacles := p.obstacles(agent, rays)
+	p.food(agent, rays, obstacles)
+
+	cellSize := float64(grid.CellSize())
+	radius := float64(agent.cfg.AgentRadius * 2)
+	// agents hit by a ray lie in the cells it crosses or within span cells of them, those
+	// touching it included
+	span := int(radius/cellSize) + 1
+	// no agent around a cell entered at t is nearer than t - margin
+	margin := float64(span+1) * cellSize * math.Sqrt2
+
+	for rayIndex, ray := range rays {
+		p.walk(agent, grid, rayIndex, ray, obstacles, cellSize, radius, span, margin)
+	}
+}
+
+// walk follows ray from the agent cell by cell. In each cell it tests the agents of the cell and
+// of the neighbors that the part of the ray inside the cell comes within radius of, until the
+// agents around the next cells are all behind what it already hit or behind an obstacle.
+func (p *DDAPerceipt) walk(agent *Agent, grid GridAgentProvider, rayIndex int, ray vector.Vector, obstacles []float64, cellSize, radius float64, span int, margin float64) {
+	x0, y0 := agent.Position[0], agent.Position[1]
+	length := math.Hypot(ray[0], ray[1])
+	dirX, dirY := ray[0]/length, ray[1]/length
+	col, row := int(math.Floor(x0/cellSize)), int(math.Floor(y0/cellSize))
+
+	// distance along the ray to the next vertical and horizontal cell borders, and between them
+	stepX, nextX, deltaX := traversal(x0, dirX, cellSize)
+	stepY, nextY, deltaY := traversal(y0, dirY, cellSize)
+
+	for t := 0.0; t <= length; {
+		if p.done(agent, rayIndex, t-margin) || hidden(obstacles, rayIndex, t-margin) {
+			return
+		}
+
+		// the part of the ray inside the cell, and the neighbors it is close to
+		exit := math.Min(length, math.Min(nextX, nextY))
+		minX, maxX := math.Min(x0+t*dirX, x0+exit*dirX), math.Max(x0+t*dirX, x0+exit*dirX)
+		minY, maxY := math.Min(y0+t*dirY, y0+exit*dirY), math.Max(y0+t*dirY, y0+exit*dirY)
+		left, right := reach(minX-float64(col)*cellSize, float64(col+1)*cellSize-maxX, radius, cellSize, span)
+		top, bottom := reach(minY-float64(row)*cellSize, float64(row+1)*cellSize-maxY, radius, cellSize, span)
+
+		for i := col - left; i <= col+right; i++ {
+			for j := row - top; j <= row+bottom; j++ {
+				c, r, ok := p.world.Cell(i, j, int(cellSize))
+				if !ok {
+					continue
+				}
+				for _, other := range grid.GetAgentsInCell(uint32(c), uint32(r)) {
+					if !p.sees(agent, other) {
+						continue
+					}
+					x, y := p.world.Delta(x0, y0, other.Position[0], other.Position[1])
+					dist := math.Sqrt(x*x + y*y)
+					if lineCircleCollision(x0, y0, x0+ray[0], y0+ray[1], x0+x, y0+y, radius) && !hidden(obstacles, rayIndex, dist) {
+						p.record(agent, other, p.channel(other.Species), rayIndex, dist)
+					}
+				}
+			}
+		}
+
+		if nextX < nextY {
+			col += stepX
+			t = nextX
+			nextX += deltaX
+		} else {
+			row += stepY
+			t = nextY
+			nextY += deltaY
+		}
+	}
+}
+
+// reach returns how many cells before and after the current one an agent hit by the ray can lie
+// in, given the gaps between the ray and both borders of the cell along an axis.
+func reach(before, after, radius, cellSize float64, span int) (int, int) {
+	cells := func(gap float64) int {
+		if gap > radius {
+			return 0
+		}
+		// a center exactly radius away from the ray is on the border of the next cell
+		return min(span, int((radius-gap)/cellSize)+1)
+	}
+	return cells(before), cells(after)
+}
+
+// traversal returns the direction of the cells crossed along an axis, the distance along the ray
+// to the first border and the distance between two borders.
+func traversal(origin, direction, cellSize float64) (step int, next, delta float64) {
+	if direction == 0 {
+		return 0, math.Inf(1), math.Inf(1)
+	}
+	delta = cellSize / math.Abs(direction)
+	cell := math.Floor(origin / cellSize)
+	if direction > 0 {
+		return 1, ((cell+1)*cellSize - origin) / direction, delta
+	}
+	return -1, (cell*cellSize - origin) / direction, delta
+}
+
+// done tells whether ray rayIndex found everything it reports nearer than bound: the nearest
+// agent and the nearest one of each agent channel.
+func (p *DDAPerceipt) done(agent *Agent, rayIndex int, bound float64) bool {
+	if !found(agent.RaysValues[rayIndex], bound) {
+		return false
+	}
+	for _, channel := range p.agentChannels {
+		if !found(agent.Channels[channel*p.rayNumber+rayIndex], bound) {
+			return false
+		}
+	}
+	return true
+}
+
+func found(dist, bound float64) bool {
+	return dist != 0 && math.Abs(dist) < bound
+}
@@ -2,6 +2,9 @@ package agents
 
 import (
 	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/terrain"
+	"Prey_Predator_MAS/topology"
+	"Prey_Predator_MAS/vegetation"
 	"fmt"
 	"github.com/quartercastle/vector"
 	"math"
@@ -11,7 +14,182 @@ type RayGenerator struct {
 	rayNumber int
 	rayLength int
 	rayAngle  float64
-	agentType int // 0 = predator, 1 = prey
+	agentType int // 0 = narrow field of view, 1 = any field of view, -1 for the grid traversal
+	// indexes in Agent.Channels of the channels of each species by name, and all of them
+	speciesChannels map[string]int
+	agentChannels   []int
+	world           topology.Topology
+
+	// ground stops the rays at walls and trees, obstacleChannel is the index of the channel
+	// reporting them, -1 when there is none
+	ground          *terrain.Map
+	obstacleChannel int
+	// vegetation is what the food channel sees, foodChannel its index or -1
+	vegetation  *vegetation.Field
+	foodChannel int
+	// signalChannel is the index of the channel reporting the signal of the nearest agent, or -1
+	signalChannel int
+}
+
+func newRayGenerator(rayNumber, rayLength int, rayAngle float64, agentType int, channels []string, world topology.Topology, ground *terrain.Map, food *vegetation.Field) RayGenerator {
+	rg := RayGenerator{
+		rayNumber:       rayNumber,
+		rayLength:       rayLength,
+		rayAngle:        rayAngle * 3.141592653589793 / 180,
+		agentType:       agentType,
+		speciesChannels: make(map[string]int),
+		world:           world,
+		ground:          ground,
+		obstacleChannel: -1,
+		vegetation:      food,
+		foodChannel:     -1,
+		signalChannel:   -1,
+	}
+	for i, channel := range channels {
+		switch channel {
+		case config.CHANNEL_OBSTACLE:
+			rg.obstacleChannel = i
+		case config.CHANNEL_FOOD:
+			rg.foodChannel = i
+		case config.CHANNEL_SIGNAL:
+			rg.signalChannel = i
+		default:
+			rg.speciesChannels[channel] = i
+			rg.agentChannels = append(rg.agentChannels, i)
+		}
+	}
+	return rg
+}
+
+// channel returns the channel that sees agents of species, -1 if there is none.
+func (rg *RayGenerator) channel(species *config.Species) int {
+	if channel, ok := rg.speciesChannels[species.Name]; ok {
+		return channel
+	}
+	return -1
+}
+
+// sees tells whether the rays of agent report other, the signal channel reporting every agent.
+func (rg *RayGenerator) sees(agent, other *Agent) bool {
+	return other.ID != agent.ID && (other.Species != agent.Species || rg.channel(other.Species) >= 0 || rg.signalChannel >= 0)
+}
+
+// castRays fills agent.RaysValues with the distance to the nearest agent hit by each ray,
+// negative for an agent of its own species, and agent.Channels with the distances in each
+// channel, the rays of the first channel then those of the next one. 0 means nothing was hit.
+// Agents behind an obstacle are hidden.
+func (rg *RayGenerator) castRays(agent *Agent, rays []vector.Vector, gatheredAgents []*Agent) {
+	clear(agent.RaysValues)
+	clear(agent.Channels)
+	obstacles := rg.obstacles(agent, rays)
+	rg.food(agent, rays, obstacles)
+
+	for _, gatheredAgent := range gatheredAgents {
+		channel := rg.channel(gatheredAgent.Species)
+		// where the agent sees the other one, across the world edges if it is closer that way
+		x, y := rg.world.Delta(agent.Position[0], agent.Position[1], gatheredAgent.Position[0], gatheredAgent.Position[1])
+		otherX, otherY := agent.Position[0]+x, agent.Position[1]+y
+		for rayIndex, ray := range rays {
+			if gatheredAgent != nil && lineCircleCollision(agent.Position[0], agent.Position.Y(), agent.Position[0]+ray[0], agent.Position.Y()+ray.Y(), otherX, otherY, float64(agent.cfg.AgentRadius*2)) {
+				dist := math.Sqrt(x*x + y*y)
+				if hidden(obstacles, rayIndex, dist) {
+					continue
+				}
+
+				rg.record(agent, gatheredAgent, channel, rayIndex, dist)
+			}
+		}
+	}
+}
+
+// record keeps the distance at which ray rayIndex of agent hit other if it is the nearest hit
+// so far, channel being that of other, and the signal of the nearest agent. Agents at the same
+// distance go to the lowest ID, and one standing on the agent reads as nothing, so that the
+// order in which the agents are met does not matter.
+func (rg *RayGenerator) record(agent, other *Agent, channel, rayIndex int, dist float64) {
+	if dist == 0 {
+		return
+	}
+	nearest := math.Abs(agent.RaysValues[rayIndex])
+	if nearest == 0 || dist < nearest || dist == nearest && other.ID < agent.nearest[rayIndex] {
+		agent.nearest[rayIndex] = other.ID
+		if other.Species == agent.Species {
+			agent.RaysValues[rayIndex] = -dist
+		} else {
+			agent.RaysValues[rayIndex] = dist
+		}
+		if rg.signalChannel >= 0 {
+			agent.Channels[rg.signalChannel*rg.rayNumber+rayIndex] = other.Signal
+		}
+	}
+	if channel >= 0 {
+		value := &agent.Channels[channel*rg.rayNumber+rayIndex]
+		if dist < *value || *value == 0 {
+			*value = dist
+		}
+	}
+}
+
+// obstacles returns the distance along each ray to the first cell of the map that stops it, 0
+// when there is none within reach, and reports them in the obstacle channel. The cell the agent
+// stands in does not count, an agent under a tree sees out of it. It returns nil without a map.
+func (rg *RayGenerator) obstacles(agent *Agent, rays []vector.Vector) []float64 {
+	if rg.ground == nil {
+		return nil
+	}
+	obstacles := make([]float64, len(rays))
+	for rayIndex, ray := range rays {
+		obstacles[rayIndex] = rg.march(agent, ray, float64(rg.ground.CellSize), rg.ground.BlocksRays)
+		if rg.obstacleChannel >= 0 {
+			agent.Channels[rg.obstacleChannel*rg.rayNumber+rayIndex] = obstacles[rayIndex]
+		}
+	}
+	return obstacles
+}
+
+// food reports in the food channel the distance along each ray to the first vegetation cell
+// worth a full bite, unless it is behind an obstacle.
+func (rg *RayGenerator) food(agent *Agent, rays []vector.Vector, obstacles []float64) {
+	if rg.vegetation == nil || rg.foodChannel < 0 {
+		return
+	}
+	for rayIndex, ray := range rays {
+		if dist := rg.march(agent, ray, float64(rg.vegetation.CellSize), rg.vegetation.Ripe); !hidden(obstacles, rayIndex, dist) {
+			agent.Channels[rg.foodChannel*rg.rayNumber+rayIndex] = dist
+		}
+	}
+}
+
+// march follows ray through square cells of cellSize and returns the distance at which it
+// enters the first cell whose center stops it, 0 when there is none within reach. The cell the
+// agent stands in is skipped.
+func (rg *RayGenerator) march(agent *Agent, ray vector.Vector, cellSize float64, stops func(x, y float64) bool) float64 {
+	x0, y0 := agent.Position[0], agent.Position[1]
+	length := math.Hypot(ray[0], ray[1])
+	col, row := math.Floor(x0/cellSize), math.Floor(y0/cellSize)
+	stepX, nextX, deltaX := traversal(x0, ray[0]/length, cellSize)
+	stepY, nextY, deltaY := traversal(y0, ray[1]/length, cellSize)
+	for {
+		var t float64
+		if nextX < nextY {
+			col, t = col+float64(stepX), nextX
+			nextX += deltaX
+		} else {
+			row, t = row+float64(stepY), nextY
+			nextY += deltaY
+		}
+		if t > length {
+			return 0
+		}
+		if stops(rg.world.Place((col+0.5)*cellSize, (row+0.5)*cellSize)) {
+			return t
+		}
+	}
+}
+
+// hidden tells whether an agent at dist along ray rayIndex is behind an obstacle.
+func hidden(obstacles []float64, rayIndex int, dist float64) bool {
+	return obstacles != nil && obstacles[rayIndex] > 0 && dist > obstacles[rayIndex]
 }
 
 // generateRays generates the rays for the predator and prey and calculates the bounding box.
@@ -55,38 +233,52 @@ func (rg *RayGenerator) generateRays(agent *Agent) (rays []vector.Vector, boundi
 
 type GridAgentProvider interface {
 	GetAgentsInCell(x, y uint32) []*Agent
+	CellSize() int
+	Bounds() (width, height int)
 }
 
 type Perceipt interface {
 	Perceive(agent *Agent, grid GridAgentProvider)
 }
 
+// NewPerceipt returns the perception of the agents of species with the given backend,
+// config.PERCEPTION_BOUNDING_BOX or config.PERCEPTION_DDA. The bounding box backend only looks at
+// the cells of the triangle between the outer rays when the arc it cuts off the field of view
+// is thinner than a grid cell.
+func NewPerceipt(perception string, cfg *config.Config, species *config.Species, world topology.Topology, ground *terrain.Map, food *vegetation.Field) Perceipt {
+	rayLength, rayAngle := species.RayLength, float64(species.RayAngleDeg)
+	switch {
+	case perception == config.PERCEPTION_DDA:
+		return NewDDAPerceipt(cfg.RayNumber, rayLength, rayAngle, cfg.Channels(), world, ground, food)
+	case rayAngle < 180 && float64(rayLength)*(1-math.Cos(rayAngle*math.Pi/360)) < float64(cfg.CellSize):
+		return NewPredatorPerceipt(cfg.RayNumber, rayLength, rayAngle, cfg.Channels(), world, ground, food)
+	default:
+		return NewPreyPerceipt(cfg.RayNumber, rayLength, rayAngle, cfg.Channels(), world, ground, food)
+	}
+}
+
 type PreyPerceipt struct {
 	RayGenerator
 }
 
-func NewPreyPerceipt(rayNumber, rayLength int, rayAngle float64) Perceipt {
+// NewPreyPerceipt looks at every cell of the bounding box of the rays, channels being config.Config.Channels.
+func NewPreyPerceipt(rayNumber, rayLength int, rayAngle float64, channels []string, world topology.Topology, ground *terrain.Map, food *vegetation.Field) Perceipt {
 	return &PreyPerceipt{
-		RayGenerator: RayGenerator{
-			rayNumber: rayNumber,
-			rayLength: rayLength,
-			rayAngle:  rayAngle * 3.141592653589793 / 180,
-			agentType: 1,
-		},
+		RayGenerator: newRayGenerator(rayNumber, rayLength, rayAngle, 1, channels, world, ground, food),
 	}
 }
 
 func (p *PreyPerceipt) Perceive(agent *Agent, grid GridAgentProvider) {
 	rays, boundingBox := p.RayGenerator.generateRays(agent)
 
-	evaluatedCells := p.evaluateCellsInFOV(agent, rays, boundingBox)
+	evaluatedCells := p.evaluateCellsInFOV(agent, rays, boundingBox, grid)
 
 	gatheredAgents := make([]*Agent, 0, 20)
 
 	for _, cell := range *evaluatedCells {
 		agentsInCell := grid.GetAgentsInCell(uint32(cell[0]), uint32(cell.Y()))
 		for _, agentInCell := range agentsInCell {
-			if agentInCell.ID != agent.ID  && agentInCell.Color != "Green" {
+			if p.sees(agent, agentInCell) {
 				gatheredAgents = append(gatheredAgents, agentInCell)
 			}
 		}
@@ -94,44 +286,20 @@ func (p *PreyPerceipt) Perceive(agent *Agent, grid GridAgentProvider) {
 
 	evaluatedCells = nil
 
-	for i := range agent.RaysValues {
-		agent.RaysValues[i] = 0
-	}
-
 	// check collision between rays and gathered agents
 	//fmt.Printf("Gathered agents: %d\n", len(gatheredAgents))
-	for _, gatheredAgent := range gatheredAgents {
-		for rayIndex, ray := range rays {
-
-			if gatheredAgent != nil && lineCircleCollision(agent.Position[0], agent.Position.Y(), agent.Position[0]+ray[0], agent.Position.Y()+ray.Y(), gatheredAgent.Position[0], gatheredAgent.Position.Y(), float64(config.GetDefaultConfig().AgentRadius*2)) {
-				x := gatheredAgent.Position[0] - agent.Position[0]
-				y := gatheredAgent.Position[1] - agent.Position[1]
-				dist := math.Sqrt(x*x + y*y)
-
-				if dist < agent.RaysValues[rayIndex] || agent.RaysValues[rayIndex] == 0 {
-					if gatheredAgent.Color == "Green" {
-					agent.RaysValues[rayIndex] = -dist
-					} else {
-						agent.RaysValues[rayIndex] = dist
-					}
-				}
-			}
-		}
-	}
+	p.castRays(agent, rays, gatheredAgents)
 }
 
 type PredatorPerceipt struct {
 	RayGenerator
 }
 
-func NewPredatorPerceipt(rayNumber, rayLength int, rayAngle float64) Perceipt {
+// NewPredatorPerceipt only looks at the cells in the field of view, which must be narrower than
+// 180 degrees, channels being config.Config.Channels.
+func NewPredatorPerceipt(rayNumber, rayLength int, rayAngle float64, channels []string, world topology.Topology, ground *terrain.Map, food *vegetation.Field) Perceipt {
 	return &PredatorPerceipt{
-		RayGenerator: RayGenerator{
-			rayNumber: rayNumber,
-			rayLength: rayLength,
-			rayAngle:  rayAngle * 3.141592653589793 / 180,
-			agentType: 0,
-		},
+		RayGenerator: newRayGenerator(rayNumber, rayLength, rayAngle, 0, channels, world, ground, food),
 	}
 }
 
@@ -139,7 +307,7 @@ func (p *PredatorPerceipt) Perceive(agent *Agent, grid GridAgentProvider) {
 	rays, boundingBox := p.RayGenerator.generateRays(agent)
 	//p.printDebugInfo(agent, rays, boundingBox)
 
-	evaluatedCells := p.evaluateCellsInFOV(agent, rays, boundingBox)
+	evaluatedCells := p.evaluateCellsInFOV(agent, rays, boundingBox, grid)
 	//p.printEvaluatedCells(evaluatedCells)
 
 	//gather agents in selected cells
@@ -148,36 +316,15 @@ func (p *PredatorPerceipt) Perceive(agent *Agent, grid GridAgentProvider) {
 	for _, cell := range *evaluatedCells {
 		agentsInCell := grid.GetAgentsInCell(uint32(cell[0]), uint32(cell.Y()))
 		for _, agentInCell := range agentsInCell {
-			if agentInCell.ID != agent.ID && agentInCell.Color != "Red" {
+			if p.sees(agent, agentInCell) {
 				gatheredAgents = append(gatheredAgents, agentInCell)
 			}
 		}
 	}
 
-	// empty agent.RaysValues
-	for i := range agent.RaysValues {
-		agent.RaysValues[i] = 0
-	}
-
 	// check collision between rays and gathered agents
 	//fmt.Printf("Gathered agents: %d\n", len(gatheredAgents))
-	for _, gatheredAgent := range gatheredAgents {
-		for rayIndex, ray := range rays {
-			if gatheredAgent != nil && lineCircleCollision(agent.Position[0], agent.Position.Y(), agent.Position[0]+ray[0], agent.Position.Y()+ray.Y(), gatheredAgent.Position[0], gatheredAgent.Position.Y(), float64(config.GetDefaultConfig().AgentRadius*2)) {
-				x := gatheredAgent.Position[0] - agent.Position[0]
-				y := gatheredAgent.Position[1] - agent.Position[1]
-				dist := math.Sqrt(x*x + y*y)
-
-				if dist < agent.RaysValues[rayIndex] || agent.RaysValues[rayIndex] == 0 {
-					if gatheredAgent.Color == "Red" {
-						agent.RaysValues[rayIndex] = -dist
-					} else {
-						agent.RaysValues[rayIndex] = dist
-					}
-				}
-			}
-		}
-	}
+	p.castRays(agent, rays, gatheredAgents)
 	//fmt.Printf("Agent %d: %v\n", agent.ID, agent.RaysValues)
 }
 
@@ -255,18 +402,16 @@ func (p *PredatorPerceipt) printDebugInfo(agent *Agent, rays []vector.Vector, bo
 }
 
 // printEvaluatedCells prints the evaluated cells.
-func (p *PredatorPerceipt) printEvaluatedCells(cells []vector.Vector) {
-	cellSize := float64(config.GetDefaultConfig().CellSize)
+func (p *PredatorPerceipt) printEvaluatedCells(cells []vector.Vector, cellSize float64) {
 	for _, cell := range cells {
 		fmt.Printf("Cell: Indexes: [%f,%f] | Coords: [%f,%f]\n", cell[0], cell.Y(), cell[0]*float64(cellSize), cell.Y()*float64(cellSize))
 	}
 }
 
 // alignToGrid aligns the given coordinates to the grid.
-func (rg *RayGenerator) alignToGrid(x, y float64) (float64, float64) {
-	cellSize := config.GetDefaultConfig().CellSize
-	// Convert to integer for bitwise operation
-	xi, yi := int(x), int(y)
+func (rg *RayGenerator) alignToGrid(x, y float64, cellSize int) (float64, float64) {
+	// Convert to integer for bitwise operation, rounding down coordinates across the edges
+	xi, yi := int(math.Floor(x)), int(math.Floor(y))
 
 	// Perform bitwise AND with CELL_SIZE - 1
 	alignedX := xi & ^(cellSize - 1)
@@ -276,65 +421,89 @@ func (rg *RayGenerator) alignToGrid(x, y float64) (float64, float64) {
 	return float64(alignedX), float64(alignedY)
 }
 
-// cellInTriangle checks if any part of the cell is within the predator's triangle of vision.
-func (rg *RayGenerator) cellInTriangle(x, y float64, agent *Agent, rays []vector.Vector) bool {
-	cellSize := float64(config.GetDefaultConfig().CellSize)
-	points := []vector.Vector{
-		{x, y},
-		{x + cellSize, y},
-		{x, y + cellSize},
-		{x + cellSize, y + cellSize},
-		{x + cellSize/2, y + cellSize/2},
+// fieldOfView returns a triangle holding every point within radius of a ray: the triangle
+// between the outer rays, long enough to hold the arc of the ray ends, grown by radius on every
+// side by scaling it around its incenter. The field of view must be narrower than 180 degrees.
+func (rg *RayGenerator) fieldOfView(agent *Agent, rays []vector.Vector, radius float64) [3]vector.Vector {
+	stretch := 1 / math.Cos(rg.rayAngle/2)
+	a := vector.Vector{agent.Position[0], agent.Position[1]}
+	b := vector.Vector{a[0] + rays[0][0]*stretch, a[1] + rays[0][1]*stretch}
+	c := vector.Vector{a[0] + rays[len(rays)-1][0]*stretch, a[1] + rays[len(rays)-1][1]*stretch}
+
+	// the incenter weighs every vertex by the length of the opposite side
+	ab, bc, ca := math.Hypot(b[0]-a[0], b[1]-a[1]), math.Hypot(c[0]-b[0], c[1]-b[1]), math.Hypot(a[0]-c[0], a[1]-c[1])
+	perimeter := ab + bc + ca
+	centerX := (bc*a[0] + ca*b[0] + ab*c[0]) / perimeter
+	centerY := (bc*a[1] + ca*b[1] + ab*c[1]) / perimeter
+	inradius := math.Abs((b[0]-a[0])*(c[1]-a[1])-(b[1]-a[1])*(c[0]-a[0])) / perimeter
+	scale := (inradius + radius) / inradius
+
+	triangle := [3]vector.Vector{a, b, c}
+	for _, vertex := range triangle {
+		vertex[0] = centerX + (vertex[0]-centerX)*scale
+		vertex[1] = centerY + (vertex[1]-centerY)*scale
 	}
-	triangle := []vector.Vector{agent.Position, agent.Position.Add(rays[0]), agent.Position.Add(rays[len(rays)-1])}
-	for _, pt := range points {
-		if pointInTriangle(pt, triangle[0], triangle[1], triangle[2]) {
-			return true
-		}
-	}
-	return false
+	return triangle
 }
 
-// evaluateCellsInFOV evaluates which cells fall within the field of view of the predator.
-func (rg *RayGenerator) evaluateCellsInFOV(agent *Agent, rays []vector.Vector, boundingBox []float64) *[]vector.Vector {
-	config := config.GetDefaultConfig()
-	minX := math.Max(0, math.Min(boundingBox[0], float64(config.Width-1)))
-	maxX := math.Max(0, math.Min(boundingBox[2], float64(config.Width-1)))
-	minY := math.Max(0, math.Min(boundingBox[1], float64(config.Height-1)))
-	maxY := math.Max(0, math.Min(boundingBox[3], float64(config.Height-1)))
+// triangleOverlapsCell tells whether the triangle and the square cell of the given size whose
+// top left corner is x, y overlap, by looking for a separating axis among the cell axes and the
+// normals of the triangle edges.
+func triangleOverlapsCell(triangle [3]vector.Vector, x, y, cellSize float64) bool {
+	minX, maxX := math.Min(triangle[0][0], math.Min(triangle[1][0], triangle[2][0])), math.Max(triangle[0][0], math.Max(triangle[1][0], triangle[2][0]))
+	minY, maxY := math.Min(triangle[0][1], math.Min(triangle[1][1], triangle[2][1])), math.Max(triangle[0][1], math.Max(triangle[1][1], triangle[2][1]))
+	if maxX < x || minX > x+cellSize || maxY < y || minY > y+cellSize {
+		return false
+	}
 
-	firstCellX, firstCellY := rg.alignToGrid(minX, minY)
-	lastCellX, lastCellY := rg.alignToGrid(maxX, maxY)
+	corners := [4][2]float64{{x, y}, {x + cellSize, y}, {x, y + cellSize}, {x + cellSize, y + cellSize}}
+	for i := 0; i < 3; i++ {
+		p, q, opposite := triangle[i], triangle[(i+1)%3], triangle[(i+2)%3]
+		normalX, normalY := q[1]-p[1], p[0]-q[0]
+		inside := normalX*(opposite[0]-p[0]) + normalY*(opposite[1]-p[1])
+		separated := true
+		for _, corner := range corners {
+			if (normalX*(corner[0]-p[0])+normalY*(corner[1]-p[1]))*inside >= 0 {
+				separated = false
+				break
+			}
+		}
+		if separated {
+			return false
+		}
+	}
+	return true
+}
 
-	if firstCellX < 0 || firstCellY < 0 || lastCellX < 0 || lastCellY < 0 {
-		fmt.Printf("ERROR: Negative cell coordinates: [%f,%f] [%f,%f]\n", firstCellX, firstCellY, lastCellX, lastCellY)
+// evaluateCellsInFOV evaluates which cells may hold an agent hit by a ray: the cells of the
+// bounding box grown by the hit radius or, with a narrow field of view, those overlapping the
+// triangle of fieldOfView. The box may cross the world edges, its cells are brought back into
+// the world by the topology and those outside of it are left out.
+func (rg *RayGenerator) evaluateCellsInFOV(agent *Agent, rays []vector.Vector, boundingBox []float64, grid GridAgentProvider) *[]vector.Vector {
+	cellSize := grid.CellSize()
+	radius := float64(agent.cfg.AgentRadius * 2)
+
+	var triangle [3]vector.Vector
+	minX, minY, maxX, maxY := boundingBox[0]-radius, boundingBox[1]-radius, boundingBox[2]+radius, boundingBox[3]+radius
+	if rg.agentType == 0 {
+		triangle = rg.fieldOfView(agent, rays, radius)
+		minX, maxX = math.Min(triangle[0][0], math.Min(triangle[1][0], triangle[2][0])), math.Max(triangle[0][0], math.Max(triangle[1][0], triangle[2][0]))
+		minY, maxY = math.Min(triangle[0][1], math.Min(triangle[1][1], triangle[2][1])), math.Max(triangle[0][1], math.Max(triangle[1][1], triangle[2][1]))
 	}
+	firstCellX, firstCellY := rg.alignToGrid(minX, minY, cellSize)
+	lastCellX, lastCellY := rg.alignToGrid(maxX, maxY, cellSize)
+
 	evaluatedCells := make([]vector.Vector, 0, 10)
-	evaluatedCells = append(evaluatedCells, vector.Vector{math.Floor(agent.Position[0] / float64(config.CellSize)), math.Floor(agent.Position.Y() / float64(config.CellSize))})
-	for x := firstCellX; x <= lastCellX; x += float64(config.CellSize) {
-		for y := firstCellY; y <= lastCellY; y += float64(config.CellSize) {
-			if rg.agentType == 0 && rg.cellInTriangle(x, y, agent, rays) {
-				evaluatedCells = append(evaluatedCells, vector.Vector{x / float64(config.CellSize), y / float64(config.CellSize)})
-			} else if rg.agentType == 1 {
-				evaluatedCells = append(evaluatedCells, vector.Vector{x / float64(config.CellSize), y / float64(config.CellSize)})
+	for x := firstCellX; x <= lastCellX; x += float64(cellSize) {
+		for y := firstCellY; y <= lastCellY; y += float64(cellSize) {
+			if rg.agentType == 0 && !triangleOverlapsCell(triangle, x, y, float64(cellSize)) {
+				continue
+			}
+			col, row, ok := rg.world.Cell(int(x)/cellSize, int(y)/cellSize, cellSize)
+			if ok {
+				evaluatedCells = append(evaluatedCells, vector.Vector{float64(col), float64(row)})
 			}
 		}
 	}
 	return &evaluatedCells
 }
-
-func pointInTriangle(pt, v1, v2, v3 vector.Vector) bool {
-	// Barycentric coordinates
-	d1 := sign(pt, v1, v2)
-	d2 := sign(pt, v2, v3)
-	d3 := sign(pt, v3, v1)
-
-	hasNeg := (d1 < 0) || (d2 < 0) || (d3 < 0)
-	hasPos := (d1 > 0) || (d2 > 0) || (d3 > 0)
-
-	return !(hasNeg && hasPos)
-}
-
-func sign(p1, p2, p3 vector.Vector) float64 {
-	return (p1[0]-p3[0])*(p2.Y()-p3.Y()) - (p2[0]-p3[0])*(p1.Y()-p3.Y())
-}
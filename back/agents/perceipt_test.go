@@ -0,0 +1,187 @@
+package agents_test
+
+import (
+	"Prey_Predator_MAS/agents"
+	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/fixedgrid"
+	"Prey_Predator_MAS/rng"
+	"Prey_Predator_MAS/terrain"
+	"Prey_Predator_MAS/topology"
+	"Prey_Predator_MAS/vegetation"
+	"flag"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/quartercastle/vector"
+)
+
+// loadConfig reads the -config and -set flags given after -args, e.g.
+//
+//	go test -run '^$' -bench Perceive ./agents -args -set species.predator.rayLength=200
+var loadConfig = config.RegisterFlags(flag.CommandLine)
+
+// placed is an agent of a scene: its species, position and heading.
+type placed struct {
+	species string
+	x, y    float64
+	vx, vy  float64
+	signal  float64
+}
+
+func crowd(n int, size float64, seed int64) []placed {
+	r := rand.New(rand.NewSource(seed))
+	scene := make([]placed, n)
+	for i := range scene {
+		species := config.SPECIES_PREY
+		if i%3 == 0 {
+			species = config.SPECIES_PREDATOR
+		}
+		scene[i] = placed{species: species, x: r.Float64() * size, y: r.Float64() * size, vx: r.Float64()*2 - 1, vy: r.Float64()*2 - 1, signal: r.Float64()*2 - 1}
+	}
+	return scene
+}
+
+// wallColumn is a map of the 128 x 128 world with a wall on the cells of column col.
+func wallColumn(col int) *terrain.Map {
+	m := &terrain.Map{CellSize: 8, Cols: 16, Rows: 16, Cells: make([]terrain.Kind, 256)}
+	for row := 0; row < m.Rows; row++ {
+		m.Cells[row*m.Cols+col] = terrain.WALL
+	}
+	return m
+}
+
+// TestPerceptionBackendsAgree checks that the grid traversal sees exactly what the bounding box
+// sees, for every agent of each scene and the rays of both species.
+func TestPerceptionBackendsAgree(t *testing.T) {
+	tests := []struct {
+		name     string
+		topology string
+		ground   *terrain.Map
+		outputs  []string
+		channels []string
+		scene    []placed
+	}{
+		{name: "crowd", topology: topology.TORUS, scene: crowd(400, 128, 1)},
+		{name: "crowd nearest", topology: topology.TORUS, channels: []string{config.CHANNEL_NEAREST}, scene: crowd(400, 128, 2)},
+		{name: "crowd signal", topology: topology.TORUS, outputs: []string{config.OUTPUT_SIGNAL}, scene: crowd(400, 128, 3)},
+		{name: "crowd walls", topology: topology.WALLS, scene: crowd(400, 128, 4)},
+		{name: "crowd behind a wall", topology: topology.TORUS, ground: wallColumn(8), scene: crowd(400, 128, 5)},
+		{name: "touching across a cell border", topology: topology.TORUS, scene: []placed{
+			{species: config.SPECIES_PREY, x: 100, y: 102, vx: 0, vy: -1},
+			{species: config.SPECIES_PREY, x: 100, y: 104},
+			{species: config.SPECIES_PREDATOR, x: 98, y: 102, vx: 1},
+			{species: config.SPECIES_PREDATOR, x: 102, y: 102, vx: -1},
+		}},
+		{name: "ties", topology: topology.TORUS, outputs: []string{config.OUTPUT_SIGNAL}, scene: []placed{
+			{species: config.SPECIES_PREDATOR, x: 50, y: 50, vx: 1},
+			{species: config.SPECIES_PREY, x: 70, y: 51, signal: 0.5},
+			{species: config.SPECIES_PREDATOR, x: 70, y: 49, signal: -0.5},
+			{species: config.SPECIES_PREY, x: 70, y: 51, signal: 1},
+			{species: config.SPECIES_PREY, x: 50, y: 50, signal: 1},
+		}},
+		{name: "across the world edges", topology: topology.TORUS, scene: []placed{
+			{species: config.SPECIES_PREDATOR, x: 1, y: 1, vx: -1, vy: -1},
+			{species: config.SPECIES_PREY, x: 126, y: 127},
+			{species: config.SPECIES_PREY, x: 127, y: 120},
+			{species: config.SPECIES_PREY, x: 121, y: 2},
+			{species: config.SPECIES_PREDATOR, x: 3, y: 126, vx: 0, vy: 1},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.Width, cfg.Height = 128, 128
+			cfg.Topology = tt.topology
+			cfg.Outputs = tt.outputs
+			cfg.OutputNeuronNumber = config.OUTPUT_NEURON_NUMBER + len(tt.outputs)
+			cfg.RayChannels = tt.channels
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			world, err := topology.New(cfg.Topology, cfg.Width, cfg.Height)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			grid := fixedgrid.NewFixedGrid(cfg)
+			population := make([]*agents.Agent, len(tt.scene))
+			for i, p := range tt.scene {
+				agent := agents.NewAgent(uint32(i+1), p.x, p.y, cfg.SpeciesNamed(p.species), nil, nil, 1, 1, &cfg, rng.New(int64(i)))
+				if p.vx != 0 || p.vy != 0 {
+					agent.Velocity = vector.Vector{p.vx, p.vy}
+				}
+				agent.Signal = p.signal
+				population[i] = agent
+				grid.AddAgent(agent)
+			}
+
+			for i := range cfg.Species {
+				species := &cfg.Species[i]
+				boundingBox := agents.NewPerceipt(config.PERCEPTION_BOUNDING_BOX, &cfg, species, world, tt.ground, nil)
+				dda := agents.NewPerceipt(config.PERCEPTION_DDA, &cfg, species, world, tt.ground, nil)
+				for _, agent := range population {
+					boundingBox.Perceive(agent, grid)
+					rays, channels := slices.Clone(agent.RaysValues), slices.Clone(agent.Channels)
+					dda.Perceive(agent, grid)
+					if !slices.Equal(rays, agent.RaysValues) {
+						t.Errorf("%s rays of agent %d: boundingBox %v, dda %v", species.Name, agent.ID, rays, agent.RaysValues)
+					}
+					if !slices.Equal(channels, agent.Channels) {
+						t.Errorf("%s channels of agent %d: boundingBox %v, dda %v", species.Name, agent.ID, channels, agent.Channels)
+					}
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPerceive compares the perception backends on agents spread over the world, each
+// iteration perceiving for every agent in sequence.
+func BenchmarkPerceive(b *testing.B) {
+	cfg, err := loadConfig()
+	if err != nil {
+		b.Fatal(err)
+	}
+	world, err := topology.New(cfg.Topology, cfg.Width, cfg.Height)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var ground *terrain.Map
+	if cfg.Terrain.Path != "" {
+		if ground, err = terrain.Load(cfg.Terrain.Path, cfg.Width, cfg.Height, cfg.Terrain.CellSize); err != nil {
+			b.Fatal(err)
+		}
+	}
+	var food *vegetation.Field
+	if cfg.Vegetation.Enabled {
+		food = vegetation.New(cfg.Width, cfg.Height, cfg.Vegetation)
+	}
+
+	for _, size := range []int{2600, 20000} {
+		grid := fixedgrid.NewFixedGrid(cfg)
+		population := make([]*agents.Agent, size)
+		r := rng.New(config.SEED)
+		for i := range population {
+			population[i] = agents.NewAgent(uint32(i+1), r.Float64()*float64(cfg.Width), r.Float64()*float64(cfg.Height), cfg.InitialSpecies(i), nil, nil, 1, 1, &cfg, r.Split())
+			grid.AddAgent(population[i])
+		}
+		for _, perception := range []string{config.PERCEPTION_BOUNDING_BOX, config.PERCEPTION_DDA} {
+			perceipts := make(map[*config.Species]agents.Perceipt, len(cfg.Species))
+			for i := range cfg.Species {
+				perceipts[&cfg.Species[i]] = agents.NewPerceipt(perception, &cfg, &cfg.Species[i], world, ground, food)
+			}
+			b.Run(fmt.Sprintf("agents=%d/%s", size, perception), func(b *testing.B) {
+				b.ReportAllocs()
+				for n := 0; n < b.N; n++ {
+					for _, agent := range population {
+						perceipts[agent.Species].Perceive(agent, grid)
+					}
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*size), "ns/agent")
+			})
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package agents
+
+import (
+	"Prey_Predator_MAS/config"
+	"math"
+)
+
+// Sensors turns what the agents of a species perceive and their own state into brain inputs:
+// the ray distances divided by the ray length, channel by channel when the rays have channels,
+// then the sensors of config.Config.SensorLayout. The signal channel is passed as is.
+type Sensors struct {
+	rayLength float64
+	channels  bool
+	// rays of the signal channel, from signalStart to signalEnd
+	signalStart, signalEnd int
+	// one reader per sensor of the layout, nil when the species lacks it
+	readers []func(agent *Agent) float64
+}
+
+// NewSensors returns the sensors of species.
+func NewSensors(cfg *config.Config, species *config.Species) *Sensors {
+	lifePoints, reproduction := species.LifePoints, species.MaxReproduction
+
+	s := &Sensors{rayLength: float64(species.RayLength), channels: len(cfg.Channels()) > 0}
+	if signal := cfg.ChannelIndex(config.CHANNEL_SIGNAL); signal >= 0 {
+		s.signalStart, s.signalEnd = signal*cfg.RayNumber, (signal+1)*cfg.RayNumber
+	}
+	for _, sensor := range cfg.SensorLayout() {
+		var reader func(agent *Agent) float64
+		if species.HasSensor(sensor) {
+			switch sensor {
+			case config.SENSOR_ENERGY:
+				reader = func(agent *Agent) float64 { return float64(agent.Energy) / float64(cfg.MaxEnergy) }
+			case config.SENSOR_HEALTH:
+				reader = func(agent *Agent) float64 { return float64(agent.LifePoints) / float64(lifePoints) }
+			case config.SENSOR_REPRODUCTION:
+				reader = func(agent *Agent) float64 { return float64(agent.Reproduction) / float64(reproduction) }
+			case config.SENSOR_DIGESTION:
+				reader = func(agent *Agent) float64 { return float64(agent.Digestion) / config.DIGESTION_TIME }
+			case config.SENSOR_SPEED:
+				// the speed decided on the previous tick
+				reader = func(agent *Agent) float64 { return agent.Speed }
+			}
+		}
+		s.readers = append(s.readers, reader)
+	}
+	return s
+}
+
+// Sense fills agent.Inputs from the last perception of the agent and returns them. Sensor values
+// are clamped to [0, 1].
+func (s *Sensors) Sense(agent *Agent) []float64 {
+	inputs := agent.Inputs
+	rays := agent.RaysValues
+	if s.channels {
+		rays = agent.Channels
+	}
+	for i, ray := range rays {
+		inputs[i] = math.Abs(ray) / s.rayLength
+	}
+	if s.channels {
+		copy(inputs[s.signalStart:s.signalEnd], rays[s.signalStart:s.signalEnd])
+	}
+	for i, reader := range s.readers {
+		value := 0.0
+		if reader != nil {
+			value = math.Max(0, math.Min(1, reader(agent)))
+		}
+		inputs[len(rays)+i] = value
+	}
+	return inputs
+}
@@ -0,0 +1,290 @@
+// Package checkpoint periodically writes environment snapshots into a directory, prunes old
+// ones according to a retention policy and finds the newest valid one to resume from.
+package checkpoint
+
+import (
+	"Prey_Predator_MAS/environment"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const filePrefix = "checkpoint-"
+const fileSuffix = ".json.gz"
+
+// Policy decides when checkpoints are written and which ones are kept.
+type Policy struct {
+	// EveryTicks writes a checkpoint on every tick that is a multiple of N, 0 disables it
+	EveryTicks uint64
+	// Every writes a checkpoint when this much time passed since the last one, 0 disables it
+	Every time.Duration
+	// KeepLast keeps the K most recent checkpoints
+	KeepLast int
+	// KeepHourly additionally keeps the newest checkpoint of each hour started within the last N
+	// hours, older checkpoints are only kept by KeepLast
+	KeepHourly int
+}
+
+type Manager struct {
+	dir      string
+	policy   Policy
+	lastTick uint64
+	lastTime time.Time
+	now      func() time.Time
+	// unreadable holds the checkpoints LoadLatest failed to load, prune keeps them
+	unreadable map[string]bool
+	// writing tracks the checkpoint written in the background by Maybe, err is its error
+	writing sync.WaitGroup
+	err     error
+}
+
+// checkpointFile is a checkpoint found on disk, the name carries its tick and creation time.
+type checkpointFile struct {
+	path string
+	tick uint64
+	time time.Time
+}
+
+func NewManager(dir string, policy Policy) (*Manager, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if policy.KeepLast < 1 {
+		policy.KeepLast = 1
+	}
+	return &Manager{
+		dir:        dir,
+		policy:     policy,
+		lastTime:   time.Now(),
+		now:        time.Now,
+		unreadable: make(map[string]bool),
+	}, nil
+}
+
+// RegisterFlags adds the checkpoint flags to fs. The returned function must be called after
+// parsing, it returns a nil manager when no directory was given.
+func RegisterFlags(fs *flag.FlagSet) func() (*Manager, error) {
+	dir := fs.String("checkpoint-dir", "", "write periodic checkpoints into this directory and resume from the newest one")
+	everyTicks := fs.Uint64("checkpoint-every-ticks", 0, "write a checkpoint every N ticks")
+	every := fs.Duration("checkpoint-every", 10*time.Minute, "write a checkpoint when this much time passed since the last one, 0 disables it")
+	keepLast := fs.Int("checkpoint-keep", 5, "number of most recent checkpoints kept")
+	keepHourly := fs.Int("checkpoint-keep-hourly", 24, "additionally keep one checkpoint for each of the last N hours")
+
+	return func() (*Manager, error) {
+		if *dir == "" {
+			return nil, nil
+		}
+		return NewManager(*dir, Policy{
+			EveryTicks: *everyTicks,
+			Every:      *every,
+			KeepLast:   *keepLast,
+			KeepHourly: *keepHourly,
+		})
+	}
+}
+
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// LastTick is the tick of the last checkpoint written by this manager.
+func (m *Manager) LastTick() uint64 {
+	return m.lastTick
+}
+
+// Due reports whether the policy asks for a checkpoint of env now.
+func (m *Manager) Due(env *environment.Environment) bool {
+	if m.policy.EveryTicks > 0 && env.TickCounter%m.policy.EveryTicks == 0 && env.TickCounter != m.lastTick {
+		return true
+	}
+	return m.policy.Every > 0 && m.now().Sub(m.lastTime) >= m.policy.Every
+}
+
+// Maybe captures a checkpoint if one is due and writes it in the background, so that the
+// simulation does not wait for the disk. Writes are serialized: a checkpoint due while the
+// previous one is still being written waits for it. The error is the one of the previous
+// background write.
+func (m *Manager) Maybe(env *environment.Environment) error {
+	if !m.Due(env) {
+		return nil
+	}
+	snap := env.Capture()
+	err := m.Wait()
+
+	now := m.now()
+	m.lastTick = snap.Tick()
+	m.lastTime = now
+	m.writing.Add(1)
+	go func() {
+		defer m.writing.Done()
+		_, m.err = m.write(snap, now)
+	}()
+	return err
+}
+
+// Wait waits for the checkpoint being written in the background and returns its error.
+func (m *Manager) Wait() error {
+	m.writing.Wait()
+	err := m.err
+	m.err = nil
+	return err
+}
+
+// Save writes a checkpoint of env once the background write is done, then prunes the
+// directory. The error also reports a failed background write.
+func (m *Manager) Save(env *environment.Environment) (string, error) {
+	snap := env.Capture()
+	err := m.Wait()
+
+	now := m.now()
+	m.lastTick = snap.Tick()
+	m.lastTime = now
+	path, saveErr := m.write(snap, now)
+	return path, errors.Join(err, saveErr)
+}
+
+// write writes snap, then prunes the directory. The file is written under a temporary name and
+// renamed so that a crash never leaves a truncated checkpoint behind.
+func (m *Manager) write(snap *environment.Snapshot, now time.Time) (string, error) {
+	tmp, err := os.CreateTemp(m.dir, ".tmp-"+filePrefix+"*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := snap.Write(tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(m.dir, fmt.Sprintf("%s%012d-%d%s", filePrefix, snap.Tick(), now.UnixNano(), fileSuffix))
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, m.prune(now)
+}
+
+// prune removes every checkpoint not kept by the retention policy at now, except the unreadable
+// ones.
+func (m *Manager) prune(now time.Time) error {
+	files, err := list(m.dir)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	for i := 0; i < len(files) && i < m.policy.KeepLast; i++ {
+		keep[files[i].path] = true
+	}
+
+	// files are sorted newest first, so the first file seen in an hour is the one kept
+	since := now.Add(-time.Duration(m.policy.KeepHourly) * time.Hour)
+	hours := make(map[int64]bool)
+	for _, file := range files {
+		hour := file.time.Truncate(time.Hour)
+		if hour.Before(since) {
+			break
+		}
+		if !hours[hour.Unix()] {
+			hours[hour.Unix()] = true
+			keep[file.path] = true
+		}
+	}
+
+	for _, file := range files {
+		if !keep[file.path] && !m.unreadable[file.path] {
+			if err := os.Remove(file.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoadLatest loads the newest checkpoint of the directory that can be read, skipping corrupted
+// ones. It returns a nil environment when the directory holds no checkpoint, and an error when
+// it holds some but none of them loads, so that a run is never silently restarted. Checkpoints
+// that failed to load are never pruned.
+func (m *Manager) LoadLatest() (*environment.Environment, string, error) {
+	files, err := list(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	for _, file := range files {
+		env, err := load(file.path)
+		if err == nil {
+			return env, file.path, nil
+		}
+		fmt.Printf("WARNING: skipping checkpoint %s: %v\n", file.path, err)
+		m.unreadable[file.path] = true
+	}
+	if len(files) > 0 {
+		return nil, "", fmt.Errorf("none of the %d checkpoints of %s can be loaded, move them away to start a new run", len(files), m.dir)
+	}
+	return nil, "", nil
+}
+
+func load(path string) (*environment.Environment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return environment.Load(file)
+}
+
+// list returns the checkpoints of dir, newest first.
+func list(dir string) ([]checkpointFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]checkpointFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		fields := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix), "-")
+		if len(fields) != 2 {
+			continue
+		}
+		tick, err1 := strconv.ParseUint(fields[0], 10, 64)
+		nanos, err2 := strconv.ParseInt(fields[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		files = append(files, checkpointFile{
+			path: filepath.Join(dir, name),
+			tick: tick,
+			time: time.Unix(0, nanos),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].time.Equal(files[j].time) {
+			return files[i].time.After(files[j].time)
+		}
+		return files[i].tick > files[j].tick
+	})
+	return files, nil
+}
@@ -0,0 +1,75 @@
+// Command experiment runs a parameter sweep described by a JSON or YAML spec and writes one
+// CSV row per run with its outcomes. The -config and -set flags give the base configuration.
+//
+//	go run ./cmd/experiment -spec sweep.yaml -out results.csv
+//
+// with sweep.yaml:
+//
+//	grid:
+//	  species.predator.mealEnergy: [300, 550]
+//	  species.prey.maxReproduction: [150, 200, 250]
+//	random:
+//	  samples: 4
+//	  ranges:
+//	    mutationRate.newConnectionRate: [20, 80]
+//	seeds: [1, 2, 3]
+//	ticks: 20000
+//	sampleEvery: 10
+package main
+
+import (
+	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/experiment"
+	"flag"
+	"log"
+	"os"
+	"runtime"
+)
+
+func main() {
+	loadConfig := config.RegisterFlags(flag.CommandLine)
+	specPath := flag.String("spec", "", "path to the sweep description (JSON or YAML)")
+	out := flag.String("out", "", "write the results table to this CSV file instead of stdout")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of simulations run in parallel")
+	flag.Parse()
+
+	if *specPath == "" {
+		log.Fatal("-spec is required")
+	}
+	base, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	spec, err := experiment.LoadSpec(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	runs, err := spec.Runs(base)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("running %d simulations on %d workers", len(runs), *workers)
+	finished := 0
+	outcomes := spec.Execute(runs, *workers, func(outcome experiment.Outcome) {
+		finished++
+		if outcome.Err != nil {
+			log.Printf("[%d/%d] run %d failed: %v", finished, len(runs), outcome.Index, outcome.Err)
+			return
+		}
+		log.Printf("[%d/%d] run %d done after %d ticks", finished, len(runs), outcome.Index, outcome.Ticks)
+	})
+
+	output := os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		output = file
+	}
+	if err := experiment.WriteCSV(output, spec.Keys(), outcomes); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,110 @@
+// Command headless runs a simulation without the web server and as fast as possible,
+// then prints a summary and optionally writes the population time series as CSV.
+package main
+
+import (
+	"Prey_Predator_MAS/checkpoint"
+	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/simulation"
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	loadConfig := config.RegisterFlags(flag.CommandLine)
+	loadCheckpoints := checkpoint.RegisterFlags(flag.CommandLine)
+	ticks := flag.Uint64("ticks", 10000, "number of ticks to run, 0 runs until a species dies out")
+	untilExtinction := flag.Bool("until-extinction", true, "stop as soon as one species dies out")
+	out := flag.String("out", "", "write the population time series to this CSV file")
+	sampleEvery := flag.Uint64("sample-every", 1, "record the populations every N ticks")
+	asJSON := flag.Bool("json", false, "print the summary as JSON")
+	flag.Parse()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	checkpoints, err := loadCheckpoints()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sim, err := simulation.Resume(cfg, checkpoints)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *ticks == 0 && !*untilExtinction {
+		log.Fatal("-ticks=0 requires -until-extinction")
+	}
+
+	opts := simulation.RunOptions{
+		MaxTicks:         *ticks,
+		StopOnExtinction: *untilExtinction,
+	}
+
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		writer := bufio.NewWriter(file)
+		defer writer.Flush()
+
+		// one column per species, e.g. tick,predator,prey
+		header := []string{"tick"}
+		for _, species := range sim.Environment().Config().Species {
+			header = append(header, species.Name)
+		}
+		fmt.Fprintln(writer, strings.Join(header, ","))
+		opts.SampleEvery = *sampleEvery
+		opts.OnSample = func(s simulation.PopulationSample) {
+			fmt.Fprint(writer, s.Tick)
+			for _, population := range s.Populations {
+				fmt.Fprintf(writer, ",%d", population)
+			}
+			fmt.Fprintln(writer)
+		}
+	}
+
+	result := sim.Run(opts)
+	if checkpoints != nil {
+		// the last tick is saved unless it was, and the background write is waited for
+		var err error
+		if checkpoints.LastTick() != sim.Environment().TickCounter {
+			_, err = checkpoints.Save(sim.Environment())
+		} else {
+			err = checkpoints.Wait()
+		}
+		if err != nil {
+			log.Print(err)
+		}
+	}
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(result)
+		return
+	}
+
+	fmt.Printf("seed:           %d\n", sim.Environment().Config().Seed)
+	fmt.Printf("ticks:          %d\n", result.Ticks)
+	for i, species := range sim.Environment().Config().Species {
+		fmt.Printf("%-16s%d\n", species.Name+":", result.Populations[i])
+	}
+	fmt.Printf("max generation: %d\n", result.MaxGeneration)
+	if result.ExtinctSpecies != "" {
+		fmt.Printf("extinct:        %s at tick %d\n", result.ExtinctSpecies, result.Ticks)
+	}
+	for _, species := range sim.Environment().Config().Species {
+		if summary, ok := result.MutationParams[species.Name]; ok {
+			fmt.Printf("%-15s weight %.3g [%.3g, %.3g], bias %.3g [%.3g, %.3g]\n", species.Name+" σ:",
+				summary.WeightStandDev.Mean, summary.WeightStandDev.Min, summary.WeightStandDev.Max,
+				summary.BiasStandDev.Mean, summary.BiasStandDev.Min, summary.BiasStandDev.Max)
+		}
+	}
+	fmt.Printf("elapsed:        %s (%.0f ticks/s)\n", result.Elapsed, float64(result.Ticks)/result.Elapsed.Seconds())
+}
@@ -1,12 +1,19 @@
 package config
 
+import (
+	"fmt"
+	"reflect"
+)
+
+const SEED = 100000
 const WIDTH = 1024
 const HEIGHT = 1024
+const TOPOLOGY = "torus" // what the world edges do, see Config.Topology
 const NUM_AGENTS = 1000
 const MAX_PREY = 2000
 const MAX_PREDATOR = 600
 const CELL_SIZE = 8
-const APPROXIMATE_MAX_AGENT_PER_CELL = 400 //NUM_AGENTS / ((HEIGHT / CELL_SIZE) * (WIDTH / CELL_SIZE)) * 4
+const CELL_CAPACITY = 8 // initial number of agents a grid cell can hold before growing
 const AGENT_RADIUS = 1
 const RAY_NUMBER = 24
 const PREDATOR_RAY_LENGTH = 80
@@ -14,6 +21,11 @@ const PREY_RAY_LENGTH = 40
 const PREDATOR_RAY_ANGLE_DEG = 30
 const PREY_RAY_ANGLE_DEG = 250
 
+// perception backends, see Config.Perception
+const PERCEPTION_BOUNDING_BOX = "boundingBox"
+const PERCEPTION_DDA = "dda"
+const PERCEPTION = PERCEPTION_BOUNDING_BOX
+
 const FRONT_SCALE_FACTOR = 5
 
 const MAX_ENERGY = 550
@@ -24,6 +36,7 @@ const PREDATOR_LIFE_POINTS = 5
 
 const PREY_ATTACK_DAMAGE = 1
 const PREDATOR_ATTACK_DAMAGE = 3
+const BITE_BACK = false // prey touching a hunter hurt it, see Config.BiteBack
 
 const PREDATOR_ENERGY_GAIN = MAX_ENERGY
 const PREY_ENERGY_GAIN = 10
@@ -33,11 +46,48 @@ const MAX_REPRODUCTION_PREY = 200
 const MAX_REPRODUCTION_PREDATOR = 300
 const PREDATOR_REPRODUCTION_GAIN = MAX_REPRODUCTION_PREDATOR
 
+// PREDATOR_BOOST_TICKS is the number of ticks the predators reproduce faster at the start
+const PREDATOR_BOOST_TICKS = 1600
+
+// offspring are placed up to this many agent radii from their parent
+const PREDATOR_BIRTH_SPREAD = 10
+const PREY_BIRTH_SPREAD = 15
+
 const ENERGY_LOSS_MULTIPLIER_SPEED = 3
 
 // NEURONS
-const INPUT_NEURON_NUMBER = RAY_NUMBER
-const OUTPUT_NEURON_NUMBER = 2
+const OUTPUT_NEURON_NUMBER = 2 // speed and rotation, plus one per extra output
+
+// extra brain outputs, see Config.Outputs
+const OUTPUT_ATTACK = "attack"
+const OUTPUT_REPRODUCE = "reproduce"
+const OUTPUT_SIGNAL = "signal"
+
+// default species, see Config.Species
+const SPECIES_PREDATOR = "predator"
+const SPECIES_PREY = "prey"
+
+// ray channels besides the species names, see Config.RayChannels
+const CHANNEL_OBSTACLE = "obstacle"
+const CHANNEL_FOOD = "food"
+
+// CHANNEL_SIGNAL reports the Signal of the nearest agent each ray hits, in [-1, 1]
+const CHANNEL_SIGNAL = "signal"
+
+// CHANNEL_NEAREST alone makes every ray report the nearest agent of another species, the single
+// input per ray of the brains evolved before the channels
+const CHANNEL_NEAREST = "nearest"
+
+// internal state sensors, see Species.Sensors
+const SENSOR_ENERGY = "energy"
+const SENSOR_HEALTH = "health"
+const SENSOR_REPRODUCTION = "reproduction"
+const SENSOR_DIGESTION = "digestion"
+const SENSOR_SPEED = "speed"
+
+// DIGESTION_TIME is the number of ticks a predator digests its prey, unable to eat again
+const DIGESTION_TIME = 10
+
 const MAX_NEURON_NUMBER = 50
 
 const NO_MUTATION = 20
@@ -47,90 +97,395 @@ const NEW_CONNECTION_RATE = 60
 const DEL_CONNECTION_RATE = 5
 const NEW_NEURON_RATE = 5
 const DEL_NEURON_RATE = 1
+const ACTIVATION_MUTATION_RATE = 2
+const NEW_RECURRENT_CONNECTION_RATE = 10
+const WEIGHT_RESET_RATE = 0
+const TOGGLE_CONNECTION_RATE = 0
+const SPLIT_NEURON_RATE = 0
+const SPLIT_NEURON_WEIGHT = 1
+const MUTATIONS_PER_BIRTH_DISTRIBUTION = "fixed"
+const MUTATIONS_PER_BIRTH = 1
+const MUTATIONS_PER_BIRTH_MAX = 0
+const SELF_ADAPTIVE_MUTATION = false
+const SELF_ADAPTATION_RATE = 0.2
+const DEBUG_BRAINS = false
+const RECURRENT_CONNECTIONS = false
 const START_MUTATION_NUMBER = 0
 const WEIGHT_MUTATION_STAND_DEV = 5
 const BIAS_MUTATION_STAND_DEV = 0.1
 
+// REPRODUCTION
+const SEXUAL_REPRODUCTION = false
+const MATE_RADIUS = 10
+
+// SPECIATION
+const SPECIATION_THRESHOLD = 1.5
+const SPECIATION_EXCESS_COEFFICIENT = 1
+const SPECIATION_DISJOINT_COEFFICIENT = 1
+const SPECIATION_WEIGHT_COEFFICIENT = 0.1
+const SPECIATION_HIDDEN_COEFFICIENT = 0.5
+const SPECIATION_EVERY = 100
+
+// GENOME LIBRARY
+const LIBRARY_PROPORTION = 1
+const LIBRARY_SEED_MUTATIONS = 0
+
+// TERRAIN
+const TERRAIN_CELL_SIZE = 4
+const TERRAIN_WATER_SPEED = 0.5
+
+// VEGETATION
+const VEGETATION_ENABLED = false
+const VEGETATION_CELL_SIZE = 8
+const VEGETATION_CAPACITY = 100
+const VEGETATION_REGROWTH = 0.01
+const VEGETATION_BITE = PREY_ENERGY_GAIN
+const VEGETATION_SEASON_AMPLITUDE = 0
+const VEGETATION_SEASON_PERIOD = 3000
+
 type Config struct {
-	Width               int `json:"width"`
-	Height              int `json:"height"`
-	NumAgents           int `json:"numAgents"`
-	CellSize            int `json:"cellSize"`
-	AgentRadius         int `json:"agentRadius"`
-	RayNumber           int `json:"rayNumber"`
-	PredatorRayLength   int `json:"predatorRayLength"`
-	PreyRayLength       int `json:"preyRayLength"`
-	PredatorRayAngleDeg int `json:"predatorRayAngleDeg"`
-	PreyRayAngleDeg     int `json:"preyRayAngleDeg"`
-	InputNeuronNumber   int `json:"inputNeuronNumber"`
-	OutputNeuronNumber  int `json:"outputNeuronNumber"`
-	PreyLifePoints      int `json:"preyLifePoints"`
-	PredatorLifePoints  int `json:"predatorLifePoints"`
-
-	PreyAttackDamage          int `json:"preyAttackDamage"`
-	PredatorAttackDamage      int `json:"predatorAttackDamage"`
-	PreyEnergyGain            int `json:"preyEnergyGain"`
-	PredatorEnergyGain        int `json:"predatorEnergyGain"`
-	PreyReproductionGain      int `json:"preyReproductionGain"`
-	PredatorReproductionGain  int `json:"predatorReproductionGain"`
+	Seed               int64 `json:"seed"`
+	Width              int   `json:"width"`
+	Height             int   `json:"height"`
+	NumAgents          int   `json:"numAgents"`
+	CellSize           int   `json:"cellSize"`
+	CellCapacity       int   `json:"cellCapacity"`
+	AgentRadius        int   `json:"agentRadius"`
+	RayNumber          int   `json:"rayNumber"`
+	InputNeuronNumber  int   `json:"inputNeuronNumber,omitempty"` // derived, see InputCount, only checked when set
+	OutputNeuronNumber int   `json:"outputNeuronNumber"`
+
+	// Topology is what happens at the world edges: "torus" wraps them, "walls" stops agents and
+	// "reflect" bounces them back
+	Topology string `json:"topology"`
+
+	// Outputs are the brain outputs that follow speed and rotation, in order: "attack" lets an
+	// agent choose when to bite, "reproduce" when to give birth once ready and "signal" sets the
+	// Signal the agent emits. Without them agents attack and reproduce by reflex.
+	Outputs []string `json:"outputs"`
+	// BiteBack makes an agent touching a hunter of its species deal it its AttackDamage. It does
+	// not depend on the attack output, which only decides when the hunter eats.
+	BiteBack bool `json:"biteBack"`
+	// RayChannels splits what the rays see: every ray reports the distance to the nearest hit of
+	// each channel, a species name, "obstacle" or "food", or the signal of the nearest agent it
+	// hits for "signal". It defaults to every species and obstacle, see Channels, and ["nearest"]
+	// keeps the single input per ray of older genomes.
+	RayChannels []string `json:"rayChannels,omitempty"`
+	// Perception is how rays find what they hit: "boundingBox" tests every agent of the field of
+	// view against every ray, "dda" walks each ray through the grid cells it crosses
+	Perception string `json:"perception"`
+	// Species are the populations of the world and their food web, see Species
+	Species []Species `json:"species"`
+
 	EnergyLossMultiplierSpeed int `json:"energyLossMultiplierSpeed"`
 	MaxSpeed                  int `json:"maxSpeed"`
 
 	ScaleFactor int `json:"scaleFactor"`
-	MaxPrey     int `json:"maxPrey"`
-	MaxPredator int `json:"maxPredator"`
 	MaxEnergy   int `json:"maxEnergy"`
+
+	MaxNeuronNumber        int          `json:"maxNeuronNumber"`
+	StartMutationNumber    int          `json:"startMutationNumber"`
+	WeightMutationStandDev float64      `json:"weightMutationStandDev"`
+	BiasMutationStandDev   float64      `json:"biasMutationStandDev"`
+	MutationRate           MutationRate `json:"mutationRate"`
+	// SpeciesMutationRate overrides mutation rates by species and operator name, e.g.
+	// {"predator": {"newNeuron": 10}}
+	SpeciesMutationRate map[string]map[string]int `json:"speciesMutationRate,omitempty"`
+	// SplitNeuronWeight is the weight of the connection into the neuron added by splitNeuron
+	SplitNeuronWeight float64           `json:"splitNeuronWeight"`
+	MutationsPerBirth MutationsPerBirth `json:"mutationsPerBirth"`
+	// SelfAdaptiveMutation gives every brain its own mutation rates and standard deviations,
+	// initialized from the config and mutated log-normally by SelfAdaptationRate at every birth
+	SelfAdaptiveMutation bool    `json:"selfAdaptiveMutation"`
+	SelfAdaptationRate   float64 `json:"selfAdaptationRate"`
+	// DebugBrains validates every brain after each mutation and panics on the first broken
+	// invariant, see Brain.Validate
+	DebugBrains bool `json:"debugBrains"`
+	// RecurrentConnections enables the connections that read the previous tick's neuron values
+	RecurrentConnections bool `json:"recurrentConnections"`
+
+	// SexualReproduction makes an agent ready to reproduce wait for a ready mate of its species
+	// within MateRadius, the offspring brain is a crossover of both parents
+	SexualReproduction bool `json:"sexualReproduction"`
+	MateRadius         int  `json:"mateRadius"`
+
+	Speciation    Speciation    `json:"speciation"`
+	GenomeLibrary GenomeLibrary `json:"genomeLibrary"`
+	Terrain       Terrain       `json:"terrain"`
+	Vegetation    Vegetation    `json:"vegetation"`
+}
+
+// Speciation clusters each population by the compatibility distance of their brains:
+// excess*E/N + disjoint*D/N + weight*W + hidden*H, with E and D the excess and disjoint
+// connections, N the size of the largest genome, W the mean weight difference of the matching
+// connections and H the difference of hidden neuron counts.
+type Speciation struct {
+	Threshold           float64 `json:"threshold"`
+	ExcessCoefficient   float64 `json:"excessCoefficient"`
+	DisjointCoefficient float64 `json:"disjointCoefficient"`
+	WeightCoefficient   float64 `json:"weightCoefficient"`
+	HiddenCoefficient   float64 `json:"hiddenCoefficient"`
+	// Every is the number of ticks between two reassignments of every agent, 0 only assigns newborns
+	Every int `json:"every"`
+}
+
+// GenomeLibrary seeds the initial population with saved brains instead of blank ones.
+type GenomeLibrary struct {
+	// Path is a directory, zip or tar.gz archive holding predator/* and prey/* genomes, "" disables it
+	Path string `json:"path"`
+	// Proportion of the initial agents of each species seeded from the library, the others get blank brains
+	Proportion float64 `json:"proportion"`
+	// SeedMutations are applied to every seeded brain so that the copies differ
+	SeedMutations int `json:"seedMutations"`
+}
+
+// Terrain covers the world with walls, trees, water and safe zones, see the terrain package.
+type Terrain struct {
+	// Path is a PNG image stretched over the world or a JSON list of polygons, "" leaves the world open
+	Path string `json:"path"`
+	// CellSize is the side of the map cells, in world units
+	CellSize int `json:"cellSize"`
+	// WaterSpeed multiplies the speed of the agents in water
+	WaterSpeed float64 `json:"waterSpeed"`
+}
+
+// Vegetation is the food of the prey, see the vegetation package.
+type Vegetation struct {
+	// Enabled makes the prey eat the vegetation under them and starve without it, instead of
+	// standing still to regain energy when exhausted
+	Enabled bool `json:"enabled"`
+	// CellSize is the side of the vegetation cells, in world units
+	CellSize int `json:"cellSize"`
+	// Capacity is the energy a fully grown cell holds
+	Capacity float64 `json:"capacity"`
+	// Regrowth is the fraction of the missing vegetation growing back every tick
+	Regrowth float64 `json:"regrowth"`
+	// Bite is the most energy a prey eats in a tick
+	Bite float64 `json:"bite"`
+	// SeasonAmplitude makes the capacity vary by up to this fraction over SeasonPeriod ticks, 0 disables seasons
+	SeasonAmplitude float64 `json:"seasonAmplitude"`
+	SeasonPeriod    int     `json:"seasonPeriod"`
 }
 
+// MutationsPerBirth is the number of mutations applied to every offspring.
+type MutationsPerBirth struct {
+	// Distribution is "fixed" (Mean rounded) or "poisson"
+	Distribution string  `json:"distribution"`
+	Mean         float64 `json:"mean"`
+	// Max caps the drawn number, 0 leaves it uncapped
+	Max int `json:"max"`
+}
+
+// MutationRate holds the relative rates of the mutation operators. The mutation tag is the name
+// of the operator in the Brain registry.
 type MutationRate struct {
-	NoMutation         int `json:"noMutation"`
-	WeightMutationRate int `json:"weightMutationRate"`
-	BiasMutationRate   int `json:"biasMutationRate"`
-	NewConnectionRate  int `json:"newConnectionRate"`
-	DelConnectionRate  int `json:"delConnectionRate"`
-	NewNeuronRate      int `json:"newNeuronRate"`
-	DelNeuronRate      int `json:"delNeuronRate"`
+	NoMutation         int `json:"noMutation" mutation:"none"`
+	WeightMutationRate int `json:"weightMutationRate" mutation:"weight"`
+	BiasMutationRate   int `json:"biasMutationRate" mutation:"bias"`
+	NewConnectionRate  int `json:"newConnectionRate" mutation:"newConnection"`
+	DelConnectionRate  int `json:"delConnectionRate" mutation:"delConnection"`
+	NewNeuronRate      int `json:"newNeuronRate" mutation:"newNeuron"`
+	DelNeuronRate      int `json:"delNeuronRate" mutation:"delNeuron"`
+	// ActivationMutationRate gives a hidden neuron another activation function
+	ActivationMutationRate int `json:"activationMutationRate" mutation:"activation"`
+	// NewRecurrentConnectionRate is only used when Config.RecurrentConnections is set
+	NewRecurrentConnectionRate int `json:"newRecurrentConnectionRate" mutation:"newRecurrentConnection"`
+	// WeightResetRate gives a connection a new random weight
+	WeightResetRate int `json:"weightResetRate" mutation:"weightReset"`
+	// ToggleConnectionRate disables an enabled connection or enables a disabled one
+	ToggleConnectionRate int `json:"toggleConnectionRate" mutation:"toggleConnection"`
+	// SplitNeuronRate adds a neuron on a connection that is disabled instead of deleted, the
+	// incoming connection weighs Config.SplitNeuronWeight
+	SplitNeuronRate int `json:"splitNeuronRate" mutation:"splitNeuron"`
+	// Custom holds the rates of the operators registered outside of the Brain package, by name
+	Custom map[string]int `json:"custom,omitempty"`
+}
+
+// Rates returns the rate of every operator by name.
+func (r MutationRate) Rates() map[string]int {
+	rates := make(map[string]int, len(r.Custom)+12)
+	v := reflect.ValueOf(r)
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("mutation"); name != "" {
+			rates[name] = int(v.Field(i).Int())
+		}
+	}
+	for name, rate := range r.Custom {
+		rates[name] = rate
+	}
+	return rates
+}
+
+// OutputIndex returns the index of the brain output named name, -1 if the layout has none.
+func (c *Config) OutputIndex(name string) int {
+	for i, output := range c.Outputs {
+		if output == name {
+			return OUTPUT_NEURON_NUMBER + i
+		}
+	}
+	return -1
+}
+
+// SensorLayout returns the sensors enabled for any species, in the order of their brain inputs.
+// The inputs are shared by every species, a species reads 0 from the sensors it lacks.
+func (c *Config) SensorLayout() []string {
+	var layout []string
+	for _, sensor := range []string{SENSOR_ENERGY, SENSOR_HEALTH, SENSOR_REPRODUCTION, SENSOR_DIGESTION, SENSOR_SPEED} {
+		for i := range c.Species {
+			if c.Species[i].HasSensor(sensor) {
+				layout = append(layout, sensor)
+				break
+			}
+		}
+	}
+	return layout
+}
+
+// Channels returns the ray channels: RayChannels when set, otherwise every species, obstacle,
+// food when the grazers live off the vegetation and signal when the agents emit one. It returns
+// nil when the rays only report the nearest agent of another species.
+func (c *Config) Channels() []string {
+	if len(c.RayChannels) == 1 && c.RayChannels[0] == CHANNEL_NEAREST {
+		return nil
+	}
+	if len(c.RayChannels) > 0 {
+		return c.RayChannels
+	}
+	channels := make([]string, 0, len(c.Species)+3)
+	for _, species := range c.Species {
+		channels = append(channels, species.Name)
+	}
+	channels = append(channels, CHANNEL_OBSTACLE)
+	if c.Vegetation.Enabled {
+		channels = append(channels, CHANNEL_FOOD)
+	}
+	if c.OutputIndex(OUTPUT_SIGNAL) >= 0 {
+		channels = append(channels, CHANNEL_SIGNAL)
+	}
+	return channels
+}
+
+// InputCount is the number of brain inputs, the bias neuron excepted: one per ray and channel,
+// then one per sensor of SensorLayout.
+func (c *Config) InputCount() int {
+	return c.RayNumber*max(1, len(c.Channels())) + len(c.SensorLayout())
+}
+
+// InputLabels names the brain inputs, the bias neuron excepted: the rays channel by channel, e.g.
+// "prey ray 3", then the sensors of SensorLayout.
+func (c *Config) InputLabels() []string {
+	labels := make([]string, 0, c.InputCount())
+	channels := c.Channels()
+	if len(channels) == 0 {
+		for i := 0; i < c.RayNumber; i++ {
+			labels = append(labels, fmt.Sprintf("ray %d", i))
+		}
+	}
+	for _, channel := range channels {
+		for i := 0; i < c.RayNumber; i++ {
+			labels = append(labels, fmt.Sprintf("%s ray %d", channel, i))
+		}
+	}
+	return append(labels, c.SensorLayout()...)
+}
+
+// ChannelIndex returns the index of the ray channel named name, -1 if there is none.
+func (c *Config) ChannelIndex(name string) int {
+	for i, channel := range c.Channels() {
+		if channel == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// SpeciesRates returns the mutation rates of the species named species, the base rates with the
+// overrides of SpeciesMutationRate applied.
+func (c *Config) SpeciesRates(species string) map[string]int {
+	rates := c.MutationRate.Rates()
+	for name, rate := range c.SpeciesMutationRate[species] {
+		rates[name] = rate
+	}
+	return rates
 }
 
 func GetDefaultConfig() Config {
 	return Config{
+		Seed:                      SEED,
+		Topology:                  TOPOLOGY,
+		Perception:                PERCEPTION,
+		BiteBack:                  BITE_BACK,
 		Width:                     WIDTH,
 		Height:                    HEIGHT,
 		NumAgents:                 NUM_AGENTS,
 		CellSize:                  CELL_SIZE,
+		CellCapacity:              CELL_CAPACITY,
 		AgentRadius:               AGENT_RADIUS,
 		RayNumber:                 RAY_NUMBER,
-		PredatorRayLength:         PREDATOR_RAY_LENGTH,
-		PreyRayLength:             PREY_RAY_LENGTH,
-		PredatorRayAngleDeg:       PREDATOR_RAY_ANGLE_DEG,
-		PreyRayAngleDeg:           PREY_RAY_ANGLE_DEG,
-		InputNeuronNumber:         INPUT_NEURON_NUMBER,
+		Species:                   DefaultSpecies(),
 		OutputNeuronNumber:        OUTPUT_NEURON_NUMBER,
-		PreyLifePoints:            PREY_LIFE_POINTS,
-		PredatorLifePoints:        PREDATOR_LIFE_POINTS,
-		PreyAttackDamage:          PREY_ATTACK_DAMAGE,
-		PredatorAttackDamage:      PREDATOR_ATTACK_DAMAGE,
-		PreyEnergyGain:            PREY_ENERGY_GAIN,
-		PredatorEnergyGain:        PREDATOR_ENERGY_GAIN,
-		PreyReproductionGain:      PREY_REPRODUCTION_GAIN,
-		PredatorReproductionGain:  PREDATOR_REPRODUCTION_GAIN,
 		EnergyLossMultiplierSpeed: ENERGY_LOSS_MULTIPLIER_SPEED,
 		MaxSpeed:                  MAX_SPEED,
 		ScaleFactor:               FRONT_SCALE_FACTOR,
-		MaxPrey:                   MAX_PREY,
-		MaxPredator:               MAX_PREDATOR,
 		MaxEnergy:                 MAX_ENERGY,
+		MaxNeuronNumber:           MAX_NEURON_NUMBER,
+		StartMutationNumber:       START_MUTATION_NUMBER,
+		WeightMutationStandDev:    WEIGHT_MUTATION_STAND_DEV,
+		BiasMutationStandDev:      BIAS_MUTATION_STAND_DEV,
+		MutationRate:              GetDefaultMutationRate(),
+		SplitNeuronWeight:         SPLIT_NEURON_WEIGHT,
+		MutationsPerBirth: MutationsPerBirth{
+			Distribution: MUTATIONS_PER_BIRTH_DISTRIBUTION,
+			Mean:         MUTATIONS_PER_BIRTH,
+			Max:          MUTATIONS_PER_BIRTH_MAX,
+		},
+		SelfAdaptiveMutation: SELF_ADAPTIVE_MUTATION,
+		SelfAdaptationRate:   SELF_ADAPTATION_RATE,
+		DebugBrains:          DEBUG_BRAINS,
+		RecurrentConnections: RECURRENT_CONNECTIONS,
+		SexualReproduction:   SEXUAL_REPRODUCTION,
+		MateRadius:           MATE_RADIUS,
+		Speciation: Speciation{
+			Threshold:           SPECIATION_THRESHOLD,
+			ExcessCoefficient:   SPECIATION_EXCESS_COEFFICIENT,
+			DisjointCoefficient: SPECIATION_DISJOINT_COEFFICIENT,
+			WeightCoefficient:   SPECIATION_WEIGHT_COEFFICIENT,
+			HiddenCoefficient:   SPECIATION_HIDDEN_COEFFICIENT,
+			Every:               SPECIATION_EVERY,
+		},
+		GenomeLibrary: GenomeLibrary{
+			Proportion:    LIBRARY_PROPORTION,
+			SeedMutations: LIBRARY_SEED_MUTATIONS,
+		},
+		Terrain: Terrain{
+			CellSize:   TERRAIN_CELL_SIZE,
+			WaterSpeed: TERRAIN_WATER_SPEED,
+		},
+		Vegetation: Vegetation{
+			Enabled:         VEGETATION_ENABLED,
+			CellSize:        VEGETATION_CELL_SIZE,
+			Capacity:        VEGETATION_CAPACITY,
+			Regrowth:        VEGETATION_REGROWTH,
+			Bite:            VEGETATION_BITE,
+			SeasonAmplitude: VEGETATION_SEASON_AMPLITUDE,
+			SeasonPeriod:    VEGETATION_SEASON_PERIOD,
+		},
 	}
 }
 
 func GetDefaultMutationRate() MutationRate {
 	return MutationRate{
-		NoMutation:         NO_MUTATION,
-		WeightMutationRate: WEIGHT_MUTATION_RATE,
-		BiasMutationRate:   BIAS_MUTATION_RATE,
-		NewConnectionRate:  NEW_CONNECTION_RATE,
-		DelConnectionRate:  DEL_CONNECTION_RATE,
-		NewNeuronRate:      NEW_NEURON_RATE,
-		DelNeuronRate:      DEL_NEURON_RATE,
+		NoMutation:                 NO_MUTATION,
+		WeightMutationRate:         WEIGHT_MUTATION_RATE,
+		BiasMutationRate:           BIAS_MUTATION_RATE,
+		NewConnectionRate:          NEW_CONNECTION_RATE,
+		DelConnectionRate:          DEL_CONNECTION_RATE,
+		NewNeuronRate:              NEW_NEURON_RATE,
+		DelNeuronRate:              DEL_NEURON_RATE,
+		ActivationMutationRate:     ACTIVATION_MUTATION_RATE,
+		NewRecurrentConnectionRate: NEW_RECURRENT_CONNECTION_RATE,
+		WeightResetRate:            WEIGHT_RESET_RATE,
+		ToggleConnectionRate:       TOGGLE_CONNECTION_RATE,
+		SplitNeuronRate:            SPLIT_NEURON_RATE,
 	}
 }
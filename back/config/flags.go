@@ -0,0 +1,47 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RegisterFlags adds the -config and -set flags to fs. The returned function must be called
+// after parsing, it merges by increasing priority the defaults, the config file, the PPS_*
+// environment variables and the -set flags, then validates the result.
+func RegisterFlags(fs *flag.FlagSet) func() (Config, error) {
+	path := fs.String("config", "", "path to a JSON, YAML or TOML configuration file")
+	var overrides []string
+	fs.Func("set", "override a config key, e.g. -set species.predator.rayLength=120 (repeatable)", func(kv string) error {
+		if !strings.Contains(kv, "=") {
+			return fmt.Errorf("expected key=value, got %q", kv)
+		}
+		overrides = append(overrides, kv)
+		return nil
+	})
+
+	return func() (Config, error) {
+		cfg := GetDefaultConfig()
+		if *path != "" {
+			var err error
+			// validated once every source is merged, an override may fix the file
+			if cfg, err = loadFile(*path); err != nil {
+				return cfg, err
+			}
+		}
+
+		if err := cfg.ApplyEnv(os.Environ()); err != nil {
+			return cfg, err
+		}
+
+		for _, kv := range overrides {
+			key, value, _ := strings.Cut(kv, "=")
+			if err := cfg.Set(key, value); err != nil {
+				return cfg, err
+			}
+		}
+
+		return cfg, cfg.Validate()
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRegisterFlagsPrecedence checks that the config file overrides the defaults, the PPS_*
+// variables the file and the -set flags everything, and that only the merged config is
+// validated.
+func TestRegisterFlagsPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		set  []string
+		// want are the expected rayNumber, maxSpeed and width, zero when an error is expected
+		want [3]int
+	}{
+		{name: "defaults", want: [3]int{RAY_NUMBER, MAX_SPEED, WIDTH}},
+		{name: "file", file: `{"rayNumber": 10, "maxSpeed": 3, "width": 512}`, want: [3]int{10, 3, 512}},
+		{name: "env over file", file: `{"rayNumber": 10, "maxSpeed": 3, "width": 512}`,
+			env: map[string]string{"PPS_RAY_NUMBER": "12", "PPS_MAX_SPEED": "4"}, want: [3]int{12, 4, 512}},
+		{name: "set over env", file: `{"rayNumber": 10, "maxSpeed": 3, "width": 512}`,
+			env: map[string]string{"PPS_RAY_NUMBER": "12", "PPS_MAX_SPEED": "4"}, set: []string{"rayNumber=14"}, want: [3]int{14, 4, 512}},
+		{name: "set over file", file: `{"rayNumber": 10}`, set: []string{"rayNumber=14"}, want: [3]int{14, MAX_SPEED, WIDTH}},
+		{name: "last set wins", set: []string{"rayNumber=14", "rayNumber=16"}, want: [3]int{16, MAX_SPEED, WIDTH}},
+		{name: "invalid file fixed by env", file: `{"rayNumber": 1}`, env: map[string]string{"PPS_RAY_NUMBER": "12"}, want: [3]int{12, MAX_SPEED, WIDTH}},
+		{name: "invalid env fixed by set", env: map[string]string{"PPS_RAY_NUMBER": "1"}, set: []string{"rayNumber=14"}, want: [3]int{14, MAX_SPEED, WIDTH}},
+		{name: "invalid set", file: `{"rayNumber": 10}`, set: []string{"rayNumber=1"}},
+		{name: "unknown variable", env: map[string]string{"PPS_NOT_A_KEY": "1"}},
+		{name: "unknown key", set: []string{"notAKey=1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			var args []string
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.json")
+				if err := os.WriteFile(path, []byte(tt.file), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "-config", path)
+			}
+			for _, kv := range tt.set {
+				args = append(args, "-set", kv)
+			}
+
+			fs := flag.NewFlagSet(tt.name, flag.ContinueOnError)
+			load := RegisterFlags(fs)
+			if err := fs.Parse(args); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := load()
+			if tt.want == [3]int{} {
+				if err == nil {
+					t.Fatal("want an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := [3]int{cfg.RayNumber, cfg.MaxSpeed, cfg.Width}; got != tt.want {
+				t.Errorf("rayNumber, maxSpeed and width %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package config
+
+import (
+	"Prey_Predator_MAS/topology"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ENV_PREFIX prefixes environment variable overrides, e.g. PPS_RAY_NUMBER=32
+const ENV_PREFIX = "PPS_"
+
+// Load reads a JSON, YAML or TOML file on top of the default configuration and validates the result.
+// Keys are the json names of the Config fields, missing keys keep their default value.
+func Load(path string) (Config, error) {
+	cfg, err := loadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// loadFile is Load without the validation, for the callers merging other sources afterwards.
+func loadFile(path string) (Config, error) {
+	cfg := GetDefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.decode(data, filepath.Ext(path)); err != nil {
+		return cfg, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// decode merges a document into the config. YAML and TOML documents are converted to JSON
+// first so that every format shares the json field names.
+func (c *Config) decode(data []byte, ext string) error {
+	switch strings.ToLower(ext) {
+	case ".json", "":
+	case ".yaml", ".yml":
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		data = converted
+	case ".toml":
+		var doc map[string]interface{}
+		if err := toml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		data = converted
+	default:
+		return fmt.Errorf("unsupported config format %q", ext)
+	}
+
+	// a species list replaces the default species instead of being merged into them by position
+	var doc map[string]json.RawMessage
+	if json.Unmarshal(data, &doc) == nil && doc["species"] != nil {
+		c.Species = nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(c)
+}
+
+// Set overrides a single field from its string representation. The key is the json name of the
+// field, nested fields are separated by dots (e.g. "mutationRate.newConnectionRate") and species
+// are found by name (e.g. "species.predator.rayLength").
+func (c *Config) Set(key, value string) error {
+	field, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		field.SetInt(v)
+	case reflect.Float64:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		field.SetFloat(v)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		field.SetBool(v)
+	case reflect.String:
+		field.SetString(value)
+	default:
+		// slices, maps and structs are given as json, slices are replaced rather than merged into
+		if field.Kind() == reflect.Slice {
+			field.Set(reflect.Zero(field.Type()))
+		}
+		if err := json.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ApplyEnv applies every PPS_* variable of environ (as returned by os.Environ) to the config.
+// Variable names are the upper snake case of the key, PPS_MUTATION_RATE_NEW_CONNECTION_RATE
+// sets "mutationRate.newConnectionRate".
+func (c *Config) ApplyEnv(environ []string) error {
+	keys := make(map[string]string)
+	for _, key := range Keys() {
+		keys[ENV_PREFIX+envName(key)] = key
+	}
+
+	var errs []error
+	for _, kv := range environ {
+		name, value, found := strings.Cut(kv, "=")
+		if !found || !strings.HasPrefix(name, ENV_PREFIX) {
+			continue
+		}
+		key, ok := keys[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown config variable %s", name))
+			continue
+		}
+		if err := c.Set(key, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Keys lists every settable key of Config in declaration order.
+func Keys() []string {
+	return collectKeys(reflect.TypeOf(Config{}), "")
+}
+
+func collectKeys(t reflect.Type, prefix string) []string {
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		if t.Field(i).Type.Kind() == reflect.Struct {
+			keys = append(keys, collectKeys(t.Field(i).Type, prefix+name+".")...)
+		} else {
+			keys = append(keys, prefix+name)
+		}
+	}
+	return keys
+}
+
+// field finds the field of key. The species are copied first since the copies of a config share
+// them, e.g. the runs of a sweep.
+func (c *Config) field(key string) (reflect.Value, bool) {
+	c.Species = slices.Clone(c.Species)
+	return lookupField(reflect.ValueOf(c).Elem(), strings.Split(key, "."))
+}
+
+func lookupField(v reflect.Value, path []string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		if jsonName(v.Type().Field(i)) != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return v.Field(i), true
+		}
+		switch field := v.Field(i); {
+		case field.Kind() == reflect.Struct:
+			return lookupField(field, path[1:])
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			// the elements of a list of named structs are found by name, e.g. "species.prey.max"
+			for j := 0; j < field.Len(); j++ {
+				if name := field.Index(j).FieldByName("Name"); name.Kind() == reflect.String && name.String() == path[1] {
+					if len(path) == 2 {
+						return field.Index(j), true
+					}
+					return lookupField(field.Index(j), path[2:])
+				}
+			}
+		}
+		return reflect.Value{}, false
+	}
+	return reflect.Value{}, false
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" || !field.IsExported() {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// envName converts "mutationRate.newConnectionRate" to "MUTATION_RATE_NEW_CONNECTION_RATE".
+func envName(key string) string {
+	var sb strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '.':
+			sb.WriteRune('_')
+		case unicode.IsUpper(r) && i > 0 && key[i-1] != '.':
+			sb.WriteRune('_')
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return sb.String()
+}
+
+// Validate checks that the configuration can run a simulation and reports every problem found.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Width > 0 && c.Height > 0, "width and height must be positive, got %dx%d", c.Width, c.Height)
+	// alignToGrid masks coordinates with CellSize-1
+	check(c.CellSize > 0 && c.CellSize&(c.CellSize-1) == 0, "cellSize must be a power of two, got %d", c.CellSize)
+	check(c.CellSize <= c.Width && c.CellSize <= c.Height, "cellSize %d is larger than the world", c.CellSize)
+	check(c.CellCapacity >= 0, "cellCapacity must not be negative, got %d", c.CellCapacity)
+	_, err := topology.New(c.Topology, c.Width, c.Height)
+	check(err == nil, "%v", err)
+	check(c.Topology != topology.TORUS || (c.CellSize > 0 && c.Width%c.CellSize == 0 && c.Height%c.CellSize == 0),
+		"width and height must be multiples of cellSize %d on a torus", c.CellSize)
+	check(c.NumAgents >= 0, "numAgents must not be negative, got %d", c.NumAgents)
+	check(c.AgentRadius > 0, "agentRadius must be positive, got %d", c.AgentRadius)
+
+	check(c.RayNumber >= 2, "rayNumber must be at least 2, got %d", c.RayNumber)
+	check(c.InputNeuronNumber == 0 || c.InputNeuronNumber == c.InputCount(), "inputNeuronNumber (%d) does not match the %d inputs of the rays and sensors, leave it out", c.InputNeuronNumber, c.InputCount())
+	check(c.Perception == PERCEPTION_BOUNDING_BOX || c.Perception == PERCEPTION_DDA, "perception must be %s or %s, got %q", PERCEPTION_BOUNDING_BOX, PERCEPTION_DDA, c.Perception)
+	check(len(c.Species) > 0, "at least one species is needed")
+	names := make(map[string]bool, len(c.Species))
+	for i := range c.Species {
+		species := &c.Species[i]
+		check(species.Name != "" && species.Name != CHANNEL_OBSTACLE && species.Name != CHANNEL_FOOD && species.Name != CHANNEL_SIGNAL && species.Name != DIET_VEGETATION,
+			"species %d: name must not be empty, %s, %s, %s or %s, got %q", i, CHANNEL_OBSTACLE, CHANNEL_FOOD, CHANNEL_SIGNAL, DIET_VEGETATION, species.Name)
+		check(!names[species.Name], "species %q is listed twice", species.Name)
+		names[species.Name] = true
+	}
+	for i := range c.Species {
+		species := &c.Species[i]
+		check(species.Share >= 0, "%s: share must not be negative, got %d", species.Name, species.Share)
+		check(species.Max >= 0, "%s: max must not be negative, got %d", species.Name, species.Max)
+		check(species.LifePoints > 0, "%s: lifePoints must be positive, got %d", species.Name, species.LifePoints)
+		check(species.MaxReproduction > 0, "%s: maxReproduction must be positive, got %d", species.Name, species.MaxReproduction)
+		check(species.BirthSpread >= 0, "%s: birthSpread must not be negative, got %d", species.Name, species.BirthSpread)
+		check(species.RayLength > 0, "%s: rayLength must be positive, got %d", species.Name, species.RayLength)
+		check(species.RayAngleDeg > 0 && species.RayAngleDeg <= 360, "%s: rayAngleDeg must be in ]0, 360], got %d", species.Name, species.RayAngleDeg)
+		// a grazer standing still to regain energy would never move again
+		check(!species.Grazes() || c.Vegetation.Enabled || species.GrazingEnergy > 0, "%s: grazingEnergy must be positive without vegetation, got %d", species.Name, species.GrazingEnergy)
+		for _, food := range species.Diet {
+			check(names[food] || food == DIET_VEGETATION, "%s: unknown diet %q, expected a species or %s", species.Name, food, DIET_VEGETATION)
+		}
+		enabled := make(map[string]bool)
+		for _, sensor := range species.Sensors {
+			check(sensor == SENSOR_ENERGY || sensor == SENSOR_HEALTH || sensor == SENSOR_REPRODUCTION || sensor == SENSOR_DIGESTION || sensor == SENSOR_SPEED,
+				"%s: unknown sensor %q, expected %s, %s, %s, %s or %s", species.Name, sensor, SENSOR_ENERGY, SENSOR_HEALTH, SENSOR_REPRODUCTION, SENSOR_DIGESTION, SENSOR_SPEED)
+			check(!enabled[sensor], "%s: sensor %q is listed twice", species.Name, sensor)
+			enabled[sensor] = true
+		}
+	}
+	check(c.NumAgents == 0 || c.totalShare() > 0, "the species shares must sum to more than zero")
+	channels := make(map[string]bool, len(c.RayChannels))
+	for _, channel := range c.RayChannels {
+		check(channel != CHANNEL_NEAREST || len(c.RayChannels) == 1, "ray channel %q cannot be combined with other channels", CHANNEL_NEAREST)
+		check(names[channel] || channel == CHANNEL_OBSTACLE || channel == CHANNEL_FOOD || channel == CHANNEL_SIGNAL || channel == CHANNEL_NEAREST,
+			"unknown ray channel %q, expected a species, %s, %s, %s or %s alone", channel, CHANNEL_OBSTACLE, CHANNEL_FOOD, CHANNEL_SIGNAL, CHANNEL_NEAREST)
+		check(channel != CHANNEL_SIGNAL || slices.Contains(c.Outputs, OUTPUT_SIGNAL), "ray channel %q needs the %q output", CHANNEL_SIGNAL, OUTPUT_SIGNAL)
+		check(!channels[channel], "ray channel %q is listed twice", channel)
+		channels[channel] = true
+	}
+	check(c.OutputNeuronNumber == OUTPUT_NEURON_NUMBER+len(c.Outputs), "outputNeuronNumber must be %d for %d extra outputs, got %d", OUTPUT_NEURON_NUMBER+len(c.Outputs), len(c.Outputs), c.OutputNeuronNumber)
+	outputs := make(map[string]bool, len(c.Outputs))
+	for _, output := range c.Outputs {
+		check(output == OUTPUT_ATTACK || output == OUTPUT_REPRODUCE || output == OUTPUT_SIGNAL, "unknown output %q, expected %s, %s or %s", output, OUTPUT_ATTACK, OUTPUT_REPRODUCE, OUTPUT_SIGNAL)
+		check(!outputs[output], "output %q is listed twice", output)
+		outputs[output] = true
+	}
+
+	check(c.MaxEnergy > 0, "maxEnergy must be positive, got %d", c.MaxEnergy)
+	check(c.MaxSpeed > 0, "maxSpeed must be positive, got %d", c.MaxSpeed)
+
+	check(c.MaxNeuronNumber >= 0, "maxNeuronNumber must not be negative, got %d", c.MaxNeuronNumber)
+	check(c.StartMutationNumber >= 0, "startMutationNumber must not be negative, got %d", c.StartMutationNumber)
+	check(c.WeightMutationStandDev >= 0 && c.BiasMutationStandDev >= 0, "mutation standard deviations must not be negative")
+
+	check(!c.SexualReproduction || c.MateRadius > 0, "mateRadius must be positive, got %d", c.MateRadius)
+	speciation := c.Speciation
+	check(speciation.Threshold > 0, "speciation.threshold must be positive, got %g", speciation.Threshold)
+	check(speciation.ExcessCoefficient >= 0 && speciation.DisjointCoefficient >= 0 && speciation.WeightCoefficient >= 0 && speciation.HiddenCoefficient >= 0,
+		"speciation coefficients must not be negative")
+	check(speciation.Every >= 0, "speciation.every must not be negative, got %d", speciation.Every)
+	check(c.GenomeLibrary.Proportion >= 0 && c.GenomeLibrary.Proportion <= 1, "genomeLibrary.proportion must be in [0, 1], got %g", c.GenomeLibrary.Proportion)
+	check(c.GenomeLibrary.SeedMutations >= 0, "genomeLibrary.seedMutations must not be negative, got %d", c.GenomeLibrary.SeedMutations)
+	// agents move at most maxSpeed per tick, a wall cell must be too thick to jump over
+	check(c.Terrain.CellSize >= c.MaxSpeed, "terrain.cellSize must be at least maxSpeed %d, got %d", c.MaxSpeed, c.Terrain.CellSize)
+	check(c.Terrain.WaterSpeed >= 0 && c.Terrain.WaterSpeed <= 1, "terrain.waterSpeed must be in [0, 1], got %g", c.Terrain.WaterSpeed)
+	vegetation := c.Vegetation
+	check(vegetation.CellSize > 0, "vegetation.cellSize must be positive, got %d", vegetation.CellSize)
+	check(vegetation.Capacity > 0, "vegetation.capacity must be positive, got %g", vegetation.Capacity)
+	check(vegetation.Regrowth >= 0 && vegetation.Regrowth <= 1, "vegetation.regrowth must be in [0, 1], got %g", vegetation.Regrowth)
+	check(vegetation.Bite >= 1, "vegetation.bite must be at least 1, got %g", vegetation.Bite)
+	check(vegetation.SeasonAmplitude >= 0 && vegetation.SeasonAmplitude <= 1, "vegetation.seasonAmplitude must be in [0, 1], got %g", vegetation.SeasonAmplitude)
+	check(vegetation.SeasonAmplitude == 0 || vegetation.SeasonPeriod > 0, "vegetation.seasonPeriod must be positive, got %d", vegetation.SeasonPeriod)
+
+	perBirth := c.MutationsPerBirth
+	check(perBirth.Distribution == "fixed" || perBirth.Distribution == "poisson", "mutationsPerBirth.distribution must be fixed or poisson, got %q", perBirth.Distribution)
+	check(perBirth.Mean >= 0, "mutationsPerBirth.mean must not be negative, got %g", perBirth.Mean)
+	check(perBirth.Max >= 0, "mutationsPerBirth.max must not be negative, got %d", perBirth.Max)
+	check(c.SelfAdaptationRate >= 0, "selfAdaptationRate must not be negative, got %g", c.SelfAdaptationRate)
+
+	for _, species := range sortedKeys(c.SpeciesMutationRate) {
+		check(names[species], "speciesMutationRate: unknown species %q", species)
+	}
+	for _, species := range c.Species {
+		rates := c.SpeciesRates(species.Name)
+		total := 0
+		for _, name := range sortedKeys(rates) {
+			check(rates[name] >= 0, "%s mutation rate %s must not be negative, got %d", species.Name, name, rates[name])
+			total += rates[name]
+		}
+		check(total > 0, "%s mutation rates must sum to more than zero", species.Name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// sortedKeys returns the keys of m in order, so that errors are reported deterministically.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SetNumber overrides a numeric field, rounding v when the field is an integer.
+func (c *Config) SetNumber(key string, v float64) error {
+	field, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+
+	switch field.Kind() {
+	case reflect.Int, reflect.Int64:
+		field.SetInt(int64(math.Round(v)))
+	case reflect.Float64:
+		field.SetFloat(v)
+	default:
+		return fmt.Errorf("config key %q is not a number", key)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package config
+
+// DIET_VEGETATION in a diet makes a species graze, see Config.Vegetation
+const DIET_VEGETATION = "vegetation"
+
+// Species holds the stats, senses and diet shared by a population of agents. Diets name the
+// species eaten, so that any food web can be declared: omnivores eating prey and vegetation,
+// two predators competing for the same prey or hunting each other.
+type Species struct {
+	// Name identifies the species in diets, ray channels, genome libraries and snapshots
+	Name string `json:"name"`
+	// Color is how the clients draw the species, "Red" and "Green" have their own sprites
+	Color string `json:"color"`
+	// Share is the part of the initial agents given to the species, relative to the other shares
+	Share int `json:"share"`
+	// Max caps the population, 0 stops it from growing
+	Max          int `json:"max"`
+	LifePoints   int `json:"lifePoints"`
+	AttackDamage int `json:"attackDamage"`
+	// Diet lists the species hunted, by name, and "vegetation" for grazers
+	Diet []string `json:"diet"`
+	// MealEnergy and MealReproduction are gained by killing a prey
+	MealEnergy       int `json:"mealEnergy"`
+	MealReproduction int `json:"mealReproduction"`
+	// GrazingEnergy is regained every tick a grazer stands still when exhausted, only used
+	// without vegetation
+	GrazingEnergy int `json:"grazingEnergy"`
+	// ReproductionGain is gained every tick, up to MaxReproduction
+	ReproductionGain int `json:"reproductionGain"`
+	MaxReproduction  int `json:"maxReproduction"`
+	// BoostTicks is the number of ticks after the start during which the species gains a
+	// ninetieth of MaxReproduction every tick
+	BoostTicks int `json:"boostTicks"`
+	// BirthSpread is the largest offset of an offspring from its parent, in agent radii
+	BirthSpread int `json:"birthSpread"`
+	RayLength   int `json:"rayLength"`
+	RayAngleDeg int `json:"rayAngleDeg"`
+	// Sensors enables internal state sensors: "energy", "health", "reproduction", "digestion"
+	// and "speed", each normalized to [0, 1], see Config.SensorLayout
+	Sensors []string `json:"sensors,omitempty"`
+}
+
+// Eats tells whether the diet of s holds name, a species or "vegetation".
+func (s *Species) Eats(name string) bool {
+	for _, food := range s.Diet {
+		if food == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Grazes tells whether s eats vegetation.
+func (s *Species) Grazes() bool {
+	return s.Eats(DIET_VEGETATION)
+}
+
+// Hunts tells whether s eats other agents, hunters are kept out of the safe zones.
+func (s *Species) Hunts() bool {
+	for _, food := range s.Diet {
+		if food != DIET_VEGETATION {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSensor tells whether sensor is enabled for s.
+func (s *Species) HasSensor(sensor string) bool {
+	for _, enabled := range s.Sensors {
+		if enabled == sensor {
+			return true
+		}
+	}
+	return false
+}
+
+// SpeciesNamed returns the species called name, nil if there is none.
+func (c *Config) SpeciesNamed(name string) *Species {
+	for i := range c.Species {
+		if c.Species[i].Name == name {
+			return &c.Species[i]
+		}
+	}
+	return nil
+}
+
+// SpeciesIndex returns the index of s in c.Species, -1 if s belongs to another config.
+func (c *Config) SpeciesIndex(s *Species) int {
+	for i := range c.Species {
+		if &c.Species[i] == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// InitialSpecies returns the species of the i-th initial agent. Agents are dealt to the species
+// in turn by share, shares of 1 and 3 give one agent of the first species, three of the second,
+// one of the first again and so on.
+func (c *Config) InitialSpecies(i int) *Species {
+	slot := i % c.totalShare()
+	for j := range c.Species {
+		if slot < c.Species[j].Share {
+			return &c.Species[j]
+		}
+		slot -= c.Species[j].Share
+	}
+	return nil
+}
+
+func (c *Config) totalShare() int {
+	total := 0
+	for _, species := range c.Species {
+		total += species.Share
+	}
+	return total
+}
+
+// DefaultSpecies returns the predators and the prey of the original simulation.
+func DefaultSpecies() []Species {
+	return []Species{
+		{
+			Name:             SPECIES_PREDATOR,
+			Color:            "Red",
+			Share:            1,
+			Max:              MAX_PREDATOR,
+			LifePoints:       PREDATOR_LIFE_POINTS,
+			AttackDamage:     PREDATOR_ATTACK_DAMAGE,
+			Diet:             []string{SPECIES_PREY},
+			MealEnergy:       PREDATOR_ENERGY_GAIN,
+			MealReproduction: PREDATOR_REPRODUCTION_GAIN,
+			MaxReproduction:  MAX_REPRODUCTION_PREDATOR,
+			BoostTicks:       PREDATOR_BOOST_TICKS,
+			BirthSpread:      PREDATOR_BIRTH_SPREAD,
+			RayLength:        PREDATOR_RAY_LENGTH,
+			RayAngleDeg:      PREDATOR_RAY_ANGLE_DEG,
+		},
+		{
+			Name:             SPECIES_PREY,
+			Color:            "Green",
+			Share:            1,
+			Max:              MAX_PREY,
+			LifePoints:       PREY_LIFE_POINTS,
+			AttackDamage:     PREY_ATTACK_DAMAGE,
+			Diet:             []string{DIET_VEGETATION},
+			GrazingEnergy:    PREY_ENERGY_GAIN,
+			ReproductionGain: PREY_REPRODUCTION_GAIN,
+			MaxReproduction:  MAX_REPRODUCTION_PREY,
+			BirthSpread:      PREY_BIRTH_SPREAD,
+			RayLength:        PREY_RAY_LENGTH,
+			RayAngleDeg:      PREY_RAY_ANGLE_DEG,
+		},
+	}
+}
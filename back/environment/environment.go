@@ -5,222 +5,607 @@ import (
 	"Prey_Predator_MAS/agents"
 	"Prey_Predator_MAS/config"
 	"Prey_Predator_MAS/fixedgrid"
+	"Prey_Predator_MAS/rng"
+	"Prey_Predator_MAS/terrain"
+	"Prey_Predator_MAS/topology"
+	"Prey_Predator_MAS/vegetation"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"runtime"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/quartercastle/vector"
 )
 
 type Environment struct {
+	cfg              config.Config
 	Width, Height    int
 	Agents           []*agents.Agent
 	IterationDone    chan bool
 	wg               sync.WaitGroup
-	fixedGrid        fixedgrid.FixedGrid
-	predatorPerceipt agents.Perceipt
-	preyPerceipt     agents.Perceipt
-	lock             sync.Mutex
+	fixedGrid        *fixedgrid.FixedGrid
+	world            topology.Topology
+	ground           *terrain.Map // nil when the world is open
+	speciesPerceipts []agents.Perceipt
+	speciesSensors   []*agents.Sensors
 	steps            int
-	PreyCount        int
-	PredatorCount    int
+	Populations      []int // living agents of each species, indexed like Config().Species
 	newAgents        []*agents.Agent
 	idCounter        uint32
 	TickCounter      uint64
+	MaxGeneration    int
 	StartTime        time.Time
+	rng              *rng.Rand
+	innovations      *Brain.Innovations
+	species          []*Species
+	speciesByID      map[uint32]*Species
+	speciesCounter   uint32
+	// mutationTables holds the mutation rates of each species, by name
+	mutationTables map[string]*Brain.MutationTable
+	mutationStats  *Brain.MutationStats
+	// indexes of the extra brain outputs, -1 when the layout has none
+	attackOutput, reproduceOutput, signalOutput int
+	// vegetation feeds the grazers, nil unless config.Vegetation.Enabled
+	vegetation *vegetation.Field
+	// AfterStep, if set, is called by Start after every tick, outside of the tick lock
+	AfterStep func(e *Environment)
+	// stepLock is held during a tick so that snapshots see a consistent world
+	stepLock sync.Mutex
+	quit     chan struct{}
+	stopOnce sync.Once
 }
 
-func NewEnvironment(width, height, numAgents, fixedGridCellSize int) *Environment {
-	config := config.GetDefaultConfig()
-	env := &Environment{
-		Width:            width,
-		Height:           height,
-		Agents:           make([]*agents.Agent, 0),
-		IterationDone:    make(chan bool),
-		fixedGrid:        fixedgrid.NewFixedGrid(),
-		predatorPerceipt: agents.NewPredatorPerceipt(config.RayNumber, config.PredatorRayLength, float64(config.PredatorRayAngleDeg)),
-		preyPerceipt:     agents.NewPreyPerceipt(config.RayNumber, config.PreyRayLength, float64(config.PreyRayAngleDeg)),
-		PreyCount:        0,
-		PredatorCount:    0,
-		newAgents:        make([]*agents.Agent, 0),
-		idCounter:        1,
-		TickCounter:      0,
-		StartTime:        time.Now(),
-	}
-
-	for i := 0; i < numAgents; i++ {
-		// init agents
-		var agentColor string
-		var perceipt agents.Perceipt
-		var lifePoints int
-		if i%2 == 0 {
-			agentColor = "Red"
-			perceipt = env.predatorPerceipt
-			lifePoints = config.PredatorLifePoints
-			env.PredatorCount++
+func NewEnvironment(config config.Config) *Environment {
+	var library Brain.Library
+	if config.GenomeLibrary.Path != "" {
+		var err error
+		if library, err = Brain.LoadLibrary(config.GenomeLibrary.Path); err != nil {
+			fmt.Printf("WARNING: genome library not loaded, starting with blank brains: %v\n", err)
+		}
+	}
+	return NewEnvironmentWithLibrary(config, library)
+}
 
-		} else {
-			agentColor = "Green"
-			perceipt = env.preyPerceipt
-			lifePoints = config.PreyLifePoints
-			env.PreyCount++
+// NewEnvironmentWithLibrary creates an environment whose initial agents are seeded, in the
+// configured proportion, with mutated copies of the library brains of their species. Agents
+// of a species without compatible brains start with blank ones.
+func NewEnvironmentWithLibrary(config config.Config, library Brain.Library) *Environment {
+	env := newEnvironment(config)
+
+	seeds := make(map[string][]*Brain.Brain, len(env.cfg.Species))
+	for _, species := range env.cfg.Species {
+		seeds[species.Name] = library.Compatible(species.Name, config.InputCount(), config.OutputNeuronNumber)
+		copies := make([]*Brain.Brain, 0, len(seeds[species.Name]))
+		for _, brain := range seeds[species.Name] {
+			// the library brains are shared, adopt copies so that the library stays untouched
+			brain, err := brain.Copy()
+			if err != nil {
+				fmt.Printf("WARNING: a %s genome of the library is skipped: %v\n", species.Name, err)
+				continue
+			}
+			env.innovations.Adopt(brain)
+			copies = append(copies, brain)
+		}
+		seeds[species.Name] = copies
+		if skipped := len(library[species.Name]) - len(seeds[species.Name]); skipped > 0 {
+			fmt.Printf("WARNING: %d %s genomes of the library do not match %d inputs and %d outputs\n", skipped, species.Name, config.InputCount(), config.OutputNeuronNumber)
+		}
+	}
+	for _, name := range library.Species() {
+		if env.cfg.SpeciesNamed(name) == nil {
+			fmt.Printf("WARNING: the %s genomes of the library match no species\n", name)
 		}
-		var x, y float64
-		x = float64(rand.Intn(width - 1))
-		y = float64(rand.Intn(height - 1))
+	}
 
-		brain := Brain.NewBrain(config.InputNeuronNumber, config.OutputNeuronNumber)
+	for i := 0; i < config.NumAgents; i++ {
+		// init agents, dealt to the species by share
+		species := env.cfg.InitialSpecies(i)
+		env.Populations[env.cfg.SpeciesIndex(species)]++
+		x, y := env.randomPosition(species)
+
+		agentRng := env.rng.Split()
+		var brain *Brain.Brain
+		if candidates := seeds[species.Name]; len(candidates) > 0 && agentRng.Float64() < config.GenomeLibrary.Proportion {
+			// the seeds were copied once already, they copy without error
+			brain, _ = candidates[agentRng.Intn(len(candidates))].Copy()
+			for m := 0; m < config.GenomeLibrary.SeedMutations; m++ {
+				env.mutate(brain, env.brainContext(species, agentRng.Rand))
+			}
+		} else {
+			var err error
+			if brain, err = Brain.NewBrain(config.InputCount(), config.OutputNeuronNumber, env.brainContext(species, agentRng.Rand)); err != nil {
+				panic(err)
+			}
+		}
 
-		env.Agents = append(env.Agents, agents.NewAgent(uint32(env.idCounter), x, y, agentColor, perceipt, brain, lifePoints, 1))
+		env.Agents = append(env.Agents, agents.NewAgent(uint32(env.idCounter), x, y, species, env.perceiptFor(species), brain, species.LifePoints, 1, &env.cfg, agentRng))
 
 		env.idCounter++
 		// add agent to fixed grid
 		env.fixedGrid.AddAgent(env.Agents[i])
+		env.assignSpecies(env.Agents[i], 0)
 	}
+	env.countSpecies()
 	return env
 }
 
+// newEnvironment creates an environment without any agent.
+func newEnvironment(config config.Config) *Environment {
+	world, err := topology.New(config.Topology, config.Width, config.Height)
+	if err != nil {
+		fmt.Printf("WARNING: %v, using a torus\n", err)
+		world, _ = topology.New(topology.TORUS, config.Width, config.Height)
+	}
+	var ground *terrain.Map
+	if config.Terrain.Path != "" {
+		if ground, err = terrain.Load(config.Terrain.Path, config.Width, config.Height, config.Terrain.CellSize); err != nil {
+			fmt.Printf("WARNING: terrain map not loaded, the world is open: %v\n", err)
+		}
+	}
+	var food *vegetation.Field
+	if config.Vegetation.Enabled {
+		food = vegetation.New(config.Width, config.Height, config.Vegetation)
+	}
+	// the agents point into the species of the environment's own copy of the config
+	config.Species = slices.Clone(config.Species)
+	env := &Environment{
+		cfg:            config,
+		Width:          config.Width,
+		Height:         config.Height,
+		Agents:         make([]*agents.Agent, 0),
+		IterationDone:  make(chan bool),
+		fixedGrid:      fixedgrid.NewFixedGrid(config),
+		world:          world,
+		ground:         ground,
+		vegetation:     food,
+		Populations:    make([]int, len(config.Species)),
+		newAgents:      make([]*agents.Agent, 0),
+		idCounter:      1,
+		TickCounter:    0,
+		MaxGeneration:  1,
+		StartTime:      time.Now(),
+		rng:            rng.New(config.Seed),
+		innovations:    Brain.NewInnovations(config.InputCount(), config.OutputNeuronNumber),
+		speciesByID:    make(map[uint32]*Species),
+		mutationTables: make(map[string]*Brain.MutationTable),
+		mutationStats:  Brain.NewMutationStats(),
+		quit:           make(chan struct{}),
+	}
+	env.indexOutputs()
+	warned := make(map[string]bool)
+	for i := range env.cfg.Species {
+		species := &env.cfg.Species[i]
+		env.speciesPerceipts = append(env.speciesPerceipts, agents.NewPerceipt(env.cfg.Perception, &env.cfg, species, world, ground, food))
+		env.speciesSensors = append(env.speciesSensors, agents.NewSensors(&env.cfg, species))
+		table, err := Brain.NewMutationTable(env.cfg.SpeciesRates(species.Name))
+		if err != nil && !warned[err.Error()] {
+			// the species share the base rates, report their unknown operators once
+			warned[err.Error()] = true
+			fmt.Printf("WARNING: mutation rates ignored: %v\n", err)
+		}
+		env.mutationTables[species.Name] = table
+	}
+	return env
+}
+
+// SpawnAgents adds up to count agents of the species named name at random positions, each with
+// its own copy of brain. Population caps are respected, the ids of the new agents are returned.
+func (e *Environment) SpawnAgents(name string, brain *Brain.Brain, count int) ([]uint32, error) {
+	species := e.cfg.SpeciesNamed(name)
+	if species == nil {
+		return nil, fmt.Errorf("unknown species %q", name)
+	}
+	genome := brain.Genome()
+	if genome.Inputs != e.cfg.InputCount()+1 || genome.Outputs != e.cfg.OutputNeuronNumber {
+		return nil, fmt.Errorf("brain has %d inputs and %d outputs, the simulation needs %d and %d",
+			genome.Inputs-1, genome.Outputs, e.cfg.InputCount(), e.cfg.OutputNeuronNumber)
+	}
+
+	brain, err := brain.Copy()
+	if err != nil {
+		return nil, err
+	}
+
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	e.innovations.Adopt(brain)
+	ids := make([]uint32, 0, count)
+	for i := 0; i < count; i++ {
+		index := e.cfg.SpeciesIndex(species)
+		if e.Populations[index] >= species.Max {
+			break
+		}
+		e.Populations[index]++
+
+		x, y := e.randomPosition(species)
+		// brain was copied above, it copies without error
+		copied, _ := brain.Copy()
+		agent := agents.NewAgent(e.idCounter, x, y, species, e.perceiptFor(species), copied, species.LifePoints, 1, &e.cfg, e.rng.Split())
+		e.idCounter++
+		e.Agents = append(e.Agents, agent)
+		e.fixedGrid.AddAgent(agent)
+		e.assignSpecies(agent, 0)
+		ids = append(ids, agent.ID)
+	}
+	e.countSpecies()
+	return ids, nil
+}
+
+// randomPosition draws a position an agent of species can stand on, giving up on the terrain map
+// after a hundred draws.
+func (e *Environment) randomPosition(species *config.Species) (float64, float64) {
+	x, y := float64(e.rng.Intn(e.Width-1)), float64(e.rng.Intn(e.Height-1))
+	for i := 0; i < 100 && e.ground.Blocks(x, y, species.Hunts()); i++ {
+		x, y = float64(e.rng.Intn(e.Width-1)), float64(e.rng.Intn(e.Height-1))
+	}
+	return x, y
+}
+
+// brainContext returns what the brain mutations of an agent of species need, drawing from r.
+func (e *Environment) brainContext(species *config.Species, r *rand.Rand) Brain.Context {
+	return Brain.Context{
+		Config:      &e.cfg,
+		Rand:        r,
+		Innovations: e.innovations,
+		Mutations:   e.mutationTables[species.Name],
+		Stats:       e.mutationStats,
+	}
+}
+
+// mutate applies one mutation to brain. A mutation for which no operator applies leaves the
+// brain unchanged, any other error comes from the debug mode, which panics on the first
+// broken brain.
+func (e *Environment) mutate(brain *Brain.Brain, ctx Brain.Context) {
+	if err := brain.Mutate(ctx); err != nil && !errors.Is(err, Brain.ErrNoMutation) {
+		panic(err)
+	}
+}
+
+// mutationsPerBirth draws the number of mutations of an offspring.
+func (e *Environment) mutationsPerBirth(r *rand.Rand) int {
+	perBirth := e.cfg.MutationsPerBirth
+	count := int(math.Round(perBirth.Mean))
+	if perBirth.Distribution == "poisson" {
+		// Knuth's algorithm, the mean is small
+		count = 0
+		limit, p := math.Exp(-perBirth.Mean), r.Float64()
+		for p > limit {
+			count++
+			p *= r.Float64()
+		}
+	}
+	if perBirth.Max > 0 {
+		count = min(count, perBirth.Max)
+	}
+	return count
+}
+
+// MutationParams summarizes, by species name, the mutation parameters evolved in the self-adaptive mode.
+func (e *Environment) MutationParams() map[string]Brain.MutationParamsSummary {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	brains := make(map[string][]*Brain.Brain)
+	for _, agent := range e.Agents {
+		brains[agent.Species.Name] = append(brains[agent.Species.Name], agent.Brain)
+	}
+	summaries := make(map[string]Brain.MutationParamsSummary, len(brains))
+	for name, population := range brains {
+		summaries[name] = Brain.SummarizeMutationParams(population)
+	}
+	return summaries
+}
+
+// MutationCounts returns how often each mutation operator fired and was inherited.
+func (e *Environment) MutationCounts() []Brain.MutationCount {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+	return e.mutationStats.Counts()
+}
+
+// perceiptFor returns the perception shared by every agent of species.
+func (e *Environment) perceiptFor(species *config.Species) agents.Perceipt {
+	return e.speciesPerceipts[e.cfg.SpeciesIndex(species)]
+}
+
+// Start runs ticks at most 60 times per second until Stop is called. After each tick it waits
+// for a reader of IterationDone.
 func (e *Environment) Start() {
 	for {
+		select {
+		case <-e.quit:
+			return
+		default:
+		}
 		start := time.Now()
 
-		// Perception phase
-		e.wg.Add(len(e.Agents))
-		for _, agent := range e.Agents {
-			if math.IsNaN(agent.Position[0]) {
-				fmt.Printf("issue")
-			}
-			go func(agent *agents.Agent, fixedGrid *fixedgrid.FixedGrid) {
-				defer e.wg.Done()
-				agent.Perceipt.Perceive(agent, fixedGrid)
-			}(agent, &e.fixedGrid)
+		e.Step()
+		if e.AfterStep != nil {
+			e.AfterStep(e)
+		}
+
+		elapsed := time.Since(start)
+
+		// limit at 60 updates per second
+		time.Sleep(time.Second/60 - elapsed)
+		select {
+		case e.IterationDone <- true:
+		case <-e.quit:
+			return
 		}
-		e.wg.Wait() // Wait for all agents to complete the perception phase
+	}
+}
 
-		// think phase
-		e.wg.Add(len(e.Agents))
+// Stop ends the Start loop and releases the goroutines waiting in LongPollIterationEnd.
+func (e *Environment) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.quit)
+	})
+}
 
-		for _, agent := range e.Agents {
-			go func(agent *agents.Agent) {
-				defer e.wg.Done()
-				//agent.Brain.Mutate()
-				agent.Speed, agent.Rotation = agent.Brain.TakeDecision(agent.RaysValues, agent.Color)
+// Step runs one tick. Perception, decision and movement run in parallel since each agent only
+// writes its own state, everything that touches other agents (grid, reproduction, collisions)
+// is then resolved sequentially in slice order so that a given seed always gives the same world.
+func (e *Environment) Step() {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	// Perception phase
+	e.wg.Add(len(e.Agents))
+	for _, agent := range e.Agents {
+		if math.IsNaN(agent.Position[0]) {
+			fmt.Printf("issue")
+		}
+		go func(agent *agents.Agent, fixedGrid *fixedgrid.FixedGrid) {
+			defer e.wg.Done()
+			agent.Perceipt.Perceive(agent, fixedGrid)
+		}(agent, e.fixedGrid)
+	}
+	e.wg.Wait() // Wait for all agents to complete the perception phase
+
+	// think phase, a decision is too short to be worth a goroutine so agents are split in one
+	// chunk per CPU
+	chunkSize := (len(e.Agents) + runtime.GOMAXPROCS(0) - 1) / runtime.GOMAXPROCS(0)
+	for start := 0; start < len(e.Agents); start += chunkSize {
+		e.wg.Add(1)
+		go func(chunk []*agents.Agent) {
+			defer e.wg.Done()
+			for _, agent := range chunk {
+				e.decide(agent, agent.Brain.TakeDecision(e.sensors(agent).Sense(agent)))
 				if agent.Speed > 1 {
 					agent.Speed = 1
 				} else if agent.Speed < 0 {
 					agent.Speed = 0
 				}
-			}(agent)
-		}
-		e.wg.Wait() // Wait for all agents to complete the perception phase
-
-		// Action phase
-		e.wg.Add(len(e.Agents))
-		for index, agent := range e.Agents {
-			go func(agent *agents.Agent, index int) {
-				defer e.wg.Done()
-				if !agent.Regen {
-					if agent.Color == "Red" && e.steps < 1600 {
-						agent.Reproduction += config.MAX_REPRODUCTION_PREDATOR / 90
-						if agent.Reproduction > config.MAX_REPRODUCTION_PREDATOR {
-							agent.Reproduction = config.MAX_REPRODUCTION_PREDATOR
-						}
+			}
+		}(e.Agents[start:min(start+chunkSize, len(e.Agents))])
+	}
+	e.wg.Wait() // Wait for all agents to complete the think phase
+
+	// Action phase: agent-local updates
+	agentCount := len(e.Agents)
+	oldPositions := make([]vector.Vector, agentCount)
+	energies := make([]int, agentCount)
+	e.wg.Add(agentCount)
+	for index, agent := range e.Agents {
+		go func(agent *agents.Agent, index int) {
+			defer e.wg.Done()
+			if !agent.Regen {
+				if e.steps < agent.Species.BoostTicks {
+					agent.Reproduction += agent.Species.MaxReproduction / 90
+					if agent.Reproduction > agent.Species.MaxReproduction {
+						agent.Reproduction = agent.Species.MaxReproduction
 					}
+				}
 
-					oldPos := agent.Move()
-					if agent.ChangedCell() {
-						e.fixedGrid.RemoveAgent(agent, oldPos)
-						e.fixedGrid.AddAgent(agent)
-					}
+				oldPositions[index] = agent.Move(e.world, e.ground)
+				energies[index], _ = agent.ApplyStatsUpdate()
+			} else {
+				if agent.Energy >= e.cfg.MaxEnergy {
+					agent.Regen = false
+					agent.Energy = e.cfg.MaxEnergy
+				} else {
+					agent.Energy += agent.Species.GrazingEnergy
+				}
+			}
+			if math.IsNaN(agent.Position[0]) {
+				fmt.Printf("issue")
+			}
+		}(agent, index)
+	}
+	e.wg.Wait() // Wait for all agents to complete the action phase
 
-					energy, _ := agent.ApplyStatsUpdate()
-					e.lock.Lock()
-					if ((agent.Reproduction >= config.MAX_REPRODUCTION_PREDATOR && agent.Color == "Red") || (agent.Reproduction >= config.MAX_REPRODUCTION_PREY && agent.Color == "Green")) && ((agent.Color == "Red" && e.PredatorCount < config.MAX_PREDATOR) ||
-						(agent.Color == "Green" && e.PreyCount < config.MAX_PREY)) {
-						// reproduction
+	// Action phase: interactions, in slice order
+	for index, agent := range e.Agents[:agentCount] {
+		if oldPositions[index] != nil {
+			e.fixedGrid.MoveAgent(agent, oldPositions[index])
+		}
+	}
 
-						randomOffset := generateRandomOffset(agent.Color)
+	for index, agent := range e.Agents[:agentCount] {
+		if agent.Regen || agent.LifePoints <= 0 {
+			continue
+		}
+		kind := agent.Species
+		population := &e.Populations[e.cfg.SpeciesIndex(kind)]
+		if kind.Grazes() {
+			agent.Energy += e.vegetation.Eat(agent.Position[0], agent.Position[1], e.cfg.MaxEnergy-agent.Energy)
+		}
 
-						var x, y float64
-						x = agent.Position.X() + randomOffset[0]
-						y = agent.Position.Y() + randomOffset[1]
+		if e.readyToReproduce(agent) && *population < kind.Max {
+			var mate *agents.Agent
+			if e.cfg.SexualReproduction {
+				mate = e.findMate(agent)
+			}
 
-						// constrain x and y to be modulo width and height
-						x = agent.WrapAround(x, float64(e.Width-1))
-						y = agent.WrapAround(y, float64(e.Height-1))
+			if mate != nil || !e.cfg.SexualReproduction {
+				// reproduction
 
-						brain := agent.Brain.Copy()
-						for i := 0; i < 1; i++ {
-							brain.Mutate()
-						}
+				randomOffset := generateRandomOffset(kind.BirthSpread, e.cfg.AgentRadius, agent.Rng.Rand)
 
-						generation := agent.Generation + 1
-						newAgent := agents.NewAgent(e.idCounter, x, y, agent.Color, agent.Perceipt, brain, agent.LifePoints, generation)
-						e.idCounter++
-						e.Agents = append(e.Agents, newAgent)
-						e.fixedGrid.AddAgent(newAgent)
-						if agent.Color == "Red" {
-							e.PredatorCount++
-						} else {
-							e.PreyCount++
-						}
+				var x, y float64
+				x = agent.Position.X() + randomOffset[0]
+				y = agent.Position.Y() + randomOffset[1]
 
-						agent.Reproduction = 0
-					}
-					e.HandleAgentCollision(agent)
-					e.lock.Unlock()
+				x, y = e.world.Place(x, y)
+				// rather next to its parent than in a wall
+				if e.ground.Blocks(x, y, kind.Hunts()) {
+					x, y = agent.Position.X(), agent.Position.Y()
+				}
 
-					if agent.Color == "Red" && energy <= 0 {
-						agent.LifePoints = 0
-					} else if agent.Color == "Green" && energy <= 0 {
-						agent.Regen = true
-					}
-				} else {
-					if agent.Energy >= config.MAX_ENERGY {
-						agent.Regen = false
-						agent.Energy = config.MAX_ENERGY
+				var brain *Brain.Brain
+				var err error
+				generation := agent.Generation + 1
+				e.mutationStats.Inherit(agent.Brain)
+				if mate != nil {
+					e.mutationStats.Inherit(mate.Brain)
+					// the fitter parent, the one with the most energy, gives its structure
+					if mate.Energy > agent.Energy {
+						brain, err = Brain.Crossover(mate.Brain, agent.Brain, agent.Rng.Rand)
 					} else {
-						agent.Energy += config.PREY_ENERGY_GAIN
+						brain, err = Brain.Crossover(agent.Brain, mate.Brain, agent.Rng.Rand)
 					}
+					generation = max(agent.Generation, mate.Generation) + 1
+					mate.Reproduction = 0
+				} else {
+					brain, err = agent.Brain.Copy()
+				}
+				if err != nil {
+					fmt.Printf("WARNING: agent %d has a broken brain and no offspring: %v\n", agent.ID, err)
+					agent.Reproduction = 0
+					e.HandleAgentCollision(agent)
+					continue
 				}
-				if math.IsNaN(agent.Position[0]) {
-					fmt.Printf("issue")
+				ctx := e.brainContext(kind, agent.Rng.Rand)
+				brain.Adapt(ctx)
+				for i := e.mutationsPerBirth(agent.Rng.Rand); i > 0; i-- {
+					e.mutate(brain, ctx)
 				}
 
-			}(agent, index)
+				if generation > e.MaxGeneration {
+					e.MaxGeneration = generation
+				}
+				newAgent := agents.NewAgent(e.idCounter, x, y, kind, agent.Perceipt, brain, agent.LifePoints, generation, &e.cfg, agent.Rng.Split())
+				e.idCounter++
+				e.Agents = append(e.Agents, newAgent)
+				e.fixedGrid.AddAgent(newAgent)
+				e.assignSpecies(newAgent, agent.SpeciesID)
+				*population++
+
+				agent.Reproduction = 0
+			}
 		}
-		e.wg.Wait() // Wait for all agents to complete the action phase
-		e.removeDeadAgents()
-		e.TickCounter++
+		e.HandleAgentCollision(agent)
 
-		elapsed := time.Since(start)
+		switch {
+		case kind.Grazes() && e.vegetation == nil:
+			// without vegetation grazing is standing still until the energy is back
+			if energies[index] <= 0 {
+				agent.Regen = true
+			}
+		case kind.Grazes():
+			// grazers living off the vegetation starve instead of regenerating
+			if agent.Energy <= 0 {
+				agent.LifePoints = 0
+			}
+		case energies[index] <= 0:
+			agent.LifePoints = 0
+		}
+	}
 
-		// limit at 60 updates per second
-		time.Sleep(time.Second/60 - elapsed)
-		fmt.Printf("Iteration took %dms - tickNb: %d - agentNb: %d\n", elapsed.Milliseconds(), e.TickCounter, len(e.Agents))
-		e.IterationDone <- true
-		e.steps++
+	e.removeDeadAgents()
+	e.vegetation.Grow(e.TickCounter)
+	e.TickCounter++
+	e.updateSpecies()
+	e.steps++
+}
+
+// indexOutputs finds the extra outputs of the brains in the configured layout.
+func (e *Environment) indexOutputs() {
+	e.attackOutput = e.cfg.OutputIndex(config.OUTPUT_ATTACK)
+	e.reproduceOutput = e.cfg.OutputIndex(config.OUTPUT_REPRODUCE)
+	e.signalOutput = e.cfg.OutputIndex(config.OUTPUT_SIGNAL)
+}
+
+// decide reads the brain outputs of an agent into its decisions.
+func (e *Environment) decide(agent *agents.Agent, outputs []float64) {
+	agent.Speed, agent.Rotation = outputs[0], outputs[1]
+	// a blank brain outputs 0, it acts until it evolves not to
+	agent.Attack = e.attackOutput < 0 || outputs[e.attackOutput] >= 0
+	agent.Reproduce = e.reproduceOutput < 0 || outputs[e.reproduceOutput] >= 0
+	if e.signalOutput >= 0 {
+		agent.Signal = math.Max(-1, math.Min(1, outputs[e.signalOutput]))
+	}
+}
+
+func (e *Environment) readyToReproduce(agent *agents.Agent) bool {
+	if !agent.Reproduce {
+		return false
+	}
+	return agent.Reproduction >= agent.Species.MaxReproduction
+}
+
+// findMate returns the nearest living agent of the same species within MateRadius that is also
+// ready to reproduce, or nil.
+func (e *Environment) findMate(agent *agents.Agent) *agents.Agent {
+	span := (e.cfg.MateRadius + e.fixedGrid.CellSize() - 1) / e.fixedGrid.CellSize()
+	col, row := e.fixedGrid.CellOf(agent.Position.X(), agent.Position.Y())
+	radiusSquared := float64(e.cfg.MateRadius * e.cfg.MateRadius)
+
+	var mate *agents.Agent
+	for _, cell := range e.cellsAround(col, row, span) {
+		for _, other := range e.fixedGrid.GetAgentsInCell(cell[0], cell[1]) {
+			if other == agent || other.Species != agent.Species || other.Regen || other.LifePoints <= 0 || !e.readyToReproduce(other) {
+				continue
+			}
+			x, y := e.world.Delta(agent.Position[0], agent.Position[1], other.Position[0], other.Position[1])
+			distSquared := x*x + y*y
+			if distSquared <= radiusSquared {
+				radiusSquared = distSquared
+				mate = other
+			}
+		}
+	}
+	return mate
+}
+
+// cellsAround returns the cells within span cells of col, row, each once, brought back into
+// the world by its topology.
+func (e *Environment) cellsAround(col, row uint32, span int) [][2]uint32 {
+	cells := make([][2]uint32, 0, (2*span+1)*(2*span+1))
+	for i := int(col) - span; i <= int(col)+span; i++ {
+		for j := int(row) - span; j <= int(row)+span; j++ {
+			c, r, ok := e.world.Cell(i, j, e.fixedGrid.CellSize())
+			// a small torus wraps several neighbors onto the same cell
+			if cell := [2]uint32{uint32(c), uint32(r)}; ok && !slices.Contains(cells, cell) {
+				cells = append(cells, cell)
+			}
+		}
 	}
+	return cells
 }
 
 func (e *Environment) HandleAgentCollision(agent *agents.Agent) {
 	// get cells around agent
 	agents := make([]*agents.Agent, 0, 40)
-	for i := uint32(0); i < 3; i++ {
-		for j := uint32(0); j < 3; j++ {
-			agents = append(agents, e.fixedGrid.GetAgentsInCell(uint32(agent.Position.X()/float64(config.GetDefaultConfig().CellSize))-1+i, uint32(agent.Position.Y()/float64(config.GetDefaultConfig().CellSize))-1+j)...)
-		}
+	col, row := e.fixedGrid.CellOf(agent.Position.X(), agent.Position.Y())
+	for _, cell := range e.cellsAround(col, row, 1) {
+		agents = append(agents, e.fixedGrid.GetAgentsInCell(cell[0], cell[1])...)
 	}
 
 	for _, otherAgent := range agents {
 		if otherAgent != nil && otherAgent != agent && otherAgent.LifePoints > 0 {
-			x := otherAgent.Position[0] - agent.Position[0]
-			y := otherAgent.Position[1] - agent.Position[1]
+			x, y := e.world.Delta(agent.Position[0], agent.Position[1], otherAgent.Position[0], otherAgent.Position[1])
 			distSquared := x*x + y*y
-			radiusSquared := float64(config.AGENT_RADIUS * 2 * config.AGENT_RADIUS * 2 * 4)
+			radiusSquared := float64(e.cfg.AgentRadius * 2 * e.cfg.AgentRadius * 2 * 4)
 
 			if distSquared < radiusSquared {
-				if agent.Color == otherAgent.Color {
+				if agent.Species == otherAgent.Species {
 
 					/*dist := math.Sqrt(distSquared)
 					separationDist := config.AGENT_RADIUS - dist/2
@@ -276,33 +661,36 @@ func (e *Environment) HandleAgentCollision(agent *agents.Agent) {
 					}
 				}
 
-				if agent.Color == "Red" && otherAgent.Color == "Green" {
-					killed := otherAgent.ApplyDamage(config.PREDATOR_ATTACK_DAMAGE)
-					if killed && agent.Digestion == 0 {
-						agent.Energy += config.PREDATOR_ENERGY_GAIN
-						if agent.Energy > config.MAX_ENERGY {
-							agent.Energy = config.MAX_ENERGY
+				// no bite across a wall
+				if e.ground.At(e.world.Place(agent.Position[0]+x/2, agent.Position[1]+y/2)) == terrain.WALL {
+					continue
+				}
+
+				if agent.Species.Eats(otherAgent.Species.Name) {
+					if agent.Attack && e.ground.At(otherAgent.Position[0], otherAgent.Position[1]) != terrain.SAFE {
+						killed := otherAgent.ApplyDamage(agent.Species.AttackDamage)
+						if killed && agent.Digestion == 0 {
+							agent.Energy += agent.Species.MealEnergy
+							if agent.Energy > e.cfg.MaxEnergy {
+								agent.Energy = e.cfg.MaxEnergy
+							}
+							agent.Digestion = config.DIGESTION_TIME
+							agent.Reproduction += agent.Species.MealReproduction
+							e.fixedGrid.RemoveAgent(otherAgent, otherAgent.Position)
 						}
-						agent.Digestion = 10
-						agent.Reproduction += config.PREDATOR_REPRODUCTION_GAIN
+					}
+				} else if e.cfg.BiteBack && otherAgent.Species.Eats(agent.Species.Name) {
+					if otherAgent.ApplyDamage(agent.Species.AttackDamage) {
 						e.fixedGrid.RemoveAgent(otherAgent, otherAgent.Position)
-						//e.PreyCount--
 					}
-				} else if agent.Color == "Green" && otherAgent.Color == "Red" {
-					/*
-						if otherAgent.ApplyDamage(config.PREY_ATTACK_DAMAGE) {
-							e.fixedGrid.RemoveAgent(otherAgent, otherAgent.Position)
-						}
-
-					*/
 				}
 			}
 		}
 	}
 }
 
-func randomDirection() (float64, float64) {
-	angle := rand.Float64() * 2 * math.Pi   // Random angle in radians
+func randomDirection(r *rand.Rand) (float64, float64) {
+	angle := r.Float64() * 2 * math.Pi      // Random angle in radians
 	return math.Cos(angle), math.Sin(angle) // Return the x and y components of the direction
 }
 
@@ -313,16 +701,92 @@ func (e *Environment) removeDeadAgents() {
 			aliveAgents = append(aliveAgents, agent)
 		} else {
 			e.fixedGrid.RemoveAgent(agent, agent.Position)
-			if agent.Color == "Red" {
-				e.PredatorCount--
-			} else {
-				e.PreyCount--
-			}
+			e.Populations[e.cfg.SpeciesIndex(agent.Species)]--
 		}
 	}
 	e.Agents = aliveAgents
 }
 
+// Config returns the configuration the environment was created with.
+func (e *Environment) Config() config.Config {
+	return e.cfg
+}
+
+// Terrain returns the terrain map, nil when the world is open.
+func (e *Environment) Terrain() *terrain.Map {
+	return e.ground
+}
+
+// Vegetation returns the vegetation averaged over blocks of scale x scale cells, false when the
+// grazers do not live off the vegetation.
+func (e *Environment) Vegetation(scale int) (vegetation.Heatmap, bool) {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	if e.vegetation == nil {
+		return vegetation.Heatmap{}, false
+	}
+	return e.vegetation.Heatmap(scale), true
+}
+
+// AgentViewModels returns the view models of the living agents, the agent selected last with
+// its brain and stats. They are built between two ticks, the brains being evaluated during them.
+func (e *Environment) AgentViewModels(selected uint32) []*agents.AgentViewModel {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	viewModels := make([]*agents.AgentViewModel, 0, len(e.Agents))
+	var selectedViewModel *agents.AgentViewModel
+	for _, agent := range e.Agents {
+		if agent.ID == selected {
+			selectedViewModel = agents.NewAgentViewModel(agent, true)
+		} else {
+			viewModels = append(viewModels, agents.NewAgentViewModel(agent, false))
+		}
+	}
+	if selectedViewModel != nil {
+		viewModels = append(viewModels, selectedViewModel)
+	}
+	return viewModels
+}
+
+// HasAgent tells whether the agent id is alive.
+func (e *Environment) HasAgent(id uint32) bool {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	for _, agent := range e.Agents {
+		if agent.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// AgentBrain returns a copy of the brain of the living agent id and the name of its species,
+// false if there is no such agent, and the error of the copy. The copy is made between two
+// ticks and can be exported while the simulation runs.
+func (e *Environment) AgentBrain(id uint32) (*Brain.Brain, string, bool, error) {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	for _, agent := range e.Agents {
+		if agent.ID == id {
+			brain, err := agent.Brain.Copy()
+			return brain, agent.Species.Name, true, err
+		}
+	}
+	return nil, "", false, nil
+}
+
+// sensors returns the sensors of the agent's species.
+func (e *Environment) sensors(agent *agents.Agent) *agents.Sensors {
+	return e.speciesSensors[e.cfg.SpeciesIndex(agent.Species)]
+}
+
 func (e *Environment) LongPollIterationEnd() {
-	<-e.IterationDone
+	select {
+	case <-e.IterationDone:
+	case <-e.quit:
+	}
 }
@@ -0,0 +1,243 @@
+package environment
+
+import (
+	"Prey_Predator_MAS/Brain"
+	"Prey_Predator_MAS/agents"
+	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/rng"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/quartercastle/vector"
+)
+
+// SNAPSHOT_VERSION is bumped whenever the snapshot layout changes, Load must then migrate the
+// snapshots of the older versions.
+const SNAPSHOT_VERSION = 1
+
+// snapshot is the gzip compressed json document written by Save.
+type snapshot struct {
+	Version       int                    `json:"version"`
+	Config        config.Config          `json:"config"`
+	TickCounter   uint64                 `json:"tickCounter"`
+	Steps         int                    `json:"steps"`
+	IDCounter     uint32                 `json:"idCounter"`
+	MaxGeneration int                    `json:"maxGeneration"`
+	ElapsedMs     int64                  `json:"elapsedMs"`
+	Rng           uint64                 `json:"rng"`
+	Innovations   Brain.InnovationsState `json:"innovations"`
+	Species       []speciesSnapshot      `json:"species"`
+	SpeciesCount  uint32                 `json:"speciesCounter"`
+	Mutations     []Brain.MutationCount  `json:"mutations"`
+	Agents        []agentSnapshot        `json:"agents"`
+	// Vegetation holds the amounts of the vegetation cells, when the grazers live off it
+	Vegetation []float64 `json:"vegetation,omitempty"`
+}
+
+type agentSnapshot struct {
+	ID           uint32       `json:"id"`
+	Position     [2]float64   `json:"pos"`
+	Velocity     [2]float64   `json:"vel"`
+	Species      string       `json:"species"`
+	Speed        float64      `json:"speed"`
+	Rotation     float64      `json:"rotation"`
+	Signal       float64      `json:"signal"`
+	LifePoints   int          `json:"lifePoints"`
+	Energy       int          `json:"energy"`
+	Reproduction int          `json:"reproduction"`
+	Digestion    int          `json:"digestion"`
+	Regen        bool         `json:"regen"`
+	Generation   int          `json:"generation"`
+	SpeciesID    uint32       `json:"speciesId"`
+	Rng          uint64       `json:"rng"`
+	Brain        Brain.Genome `json:"brain"`
+	// BrainState holds the neuron values read by recurrent connections on the next tick
+	BrainState []float64 `json:"brainState"`
+	// Mutations are the mutations of the brain counted by the mutation stats
+	Mutations []Brain.MutationRecord `json:"mutations,omitempty"`
+}
+
+type speciesSnapshot struct {
+	ID             uint32       `json:"id"`
+	Population     string       `json:"population"`
+	Founder        uint32       `json:"founder"`
+	Born           uint64       `json:"born"`
+	Representative Brain.Genome `json:"representative"`
+}
+
+// Snapshot is the world captured between two ticks by Capture. It shares nothing with the
+// environment and can be written while the simulation runs.
+type Snapshot struct {
+	snap snapshot
+}
+
+// Tick is the tick the snapshot was captured at.
+func (s *Snapshot) Tick() uint64 {
+	return s.snap.TickCounter
+}
+
+// Save writes the whole world, including the random streams, between two ticks. Loading it
+// back resumes the exact same simulation.
+func (e *Environment) Save(w io.Writer) error {
+	return e.Capture().Write(w)
+}
+
+// Capture copies the whole world between two ticks, Write then writes it as Save does.
+func (e *Environment) Capture() *Snapshot {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	snap := snapshot{
+		Version:       SNAPSHOT_VERSION,
+		Config:        e.cfg,
+		TickCounter:   e.TickCounter,
+		Steps:         e.steps,
+		IDCounter:     e.idCounter,
+		MaxGeneration: e.MaxGeneration,
+		ElapsedMs:     time.Since(e.StartTime).Milliseconds(),
+		Rng:           e.rng.State(),
+		Innovations:   e.innovations.State(),
+		Species:       make([]speciesSnapshot, 0, len(e.species)),
+		SpeciesCount:  e.speciesCounter,
+		Mutations:     e.mutationStats.Counts(),
+		Agents:        make([]agentSnapshot, 0, len(e.Agents)),
+	}
+	if e.vegetation != nil {
+		snap.Vegetation = slices.Clone(e.vegetation.Amounts)
+	}
+
+	for _, agent := range e.Agents {
+		snap.Agents = append(snap.Agents, agentSnapshot{
+			ID:           agent.ID,
+			Position:     [2]float64{agent.Position[0], agent.Position[1]},
+			Velocity:     [2]float64{agent.Velocity[0], agent.Velocity[1]},
+			Species:      agent.Species.Name,
+			Speed:        agent.Speed,
+			Rotation:     agent.Rotation,
+			Signal:       agent.Signal,
+			LifePoints:   agent.LifePoints,
+			Energy:       agent.Energy,
+			Reproduction: agent.Reproduction,
+			Digestion:    agent.Digestion,
+			Regen:        agent.Regen,
+			Generation:   agent.Generation,
+			SpeciesID:    agent.SpeciesID,
+			Rng:          agent.Rng.State(),
+			Brain:        agent.Brain.Genome(),
+			BrainState:   agent.Brain.State(),
+			Mutations:    agent.Brain.Mutations(),
+		})
+	}
+
+	for _, s := range e.species {
+		snap.Species = append(snap.Species, speciesSnapshot{
+			ID:             s.ID,
+			Population:     s.Population,
+			Founder:        s.Founder,
+			Born:           s.Born,
+			Representative: s.representative.Genome(),
+		})
+	}
+	return &Snapshot{snap: snap}
+}
+
+// Write writes the snapshot as a gzip compressed json document.
+func (s *Snapshot) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(s.snap); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Load reads a snapshot written by Save. The returned environment is not started.
+func Load(r io.Reader) (*Environment, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	defer zr.Close()
+
+	var snap snapshot
+	if err := json.NewDecoder(zr).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	if snap.Version != SNAPSHOT_VERSION {
+		return nil, fmt.Errorf("snapshot: unsupported version %d, expected %d", snap.Version, SNAPSHOT_VERSION)
+	}
+	if err := snap.Config.Validate(); err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	env := newEnvironment(snap.Config)
+	env.TickCounter = snap.TickCounter
+	env.steps = snap.Steps
+	env.idCounter = snap.IDCounter
+	env.MaxGeneration = snap.MaxGeneration
+	env.StartTime = time.Now().Add(-time.Duration(snap.ElapsedMs) * time.Millisecond)
+	env.rng.SetState(snap.Rng)
+	env.innovations = Brain.RestoreInnovations(snap.Innovations)
+	env.speciesCounter = snap.SpeciesCount
+	env.mutationStats = Brain.RestoreMutationStats(snap.Mutations)
+	if env.vegetation != nil {
+		if err := env.vegetation.Restore(snap.Vegetation); err != nil {
+			return nil, fmt.Errorf("snapshot: %w", err)
+		}
+	}
+	for _, saved := range snap.Species {
+		representative, err := Brain.FromGenome(saved.Representative)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: species %d: %w", saved.ID, err)
+		}
+		s := &Species{
+			ID:             saved.ID,
+			Population:     saved.Population,
+			Founder:        saved.Founder,
+			Born:           saved.Born,
+			representative: representative,
+		}
+		env.species = append(env.species, s)
+		env.speciesByID[s.ID] = s
+	}
+
+	for _, saved := range snap.Agents {
+		brain, err := Brain.FromGenome(saved.Brain)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: agent %d: %w", saved.ID, err)
+		}
+		if err := brain.SetState(saved.BrainState); err != nil {
+			return nil, fmt.Errorf("snapshot: agent %d: %w", saved.ID, err)
+		}
+		brain.SetMutations(saved.Mutations)
+
+		species := env.cfg.SpeciesNamed(saved.Species)
+		if species == nil {
+			return nil, fmt.Errorf("snapshot: agent %d: unknown species %q", saved.ID, saved.Species)
+		}
+
+		agentRng := rng.New(0)
+		agentRng.SetState(saved.Rng)
+		agent := agents.NewAgent(saved.ID, saved.Position[0], saved.Position[1], species, env.perceiptFor(species), brain, saved.LifePoints, saved.Generation, &env.cfg, rng.New(0))
+		agent.Rng = agentRng
+		agent.Velocity = vector.Vector{saved.Velocity[0], saved.Velocity[1]}
+		agent.Speed = saved.Speed
+		agent.Rotation = saved.Rotation
+		agent.Signal = saved.Signal
+		agent.Energy = saved.Energy
+		agent.Reproduction = saved.Reproduction
+		agent.Digestion = saved.Digestion
+		agent.Regen = saved.Regen
+		agent.SpeciesID = saved.SpeciesID
+
+		env.Agents = append(env.Agents, agent)
+		env.fixedGrid.AddAgent(agent)
+		env.Populations[env.cfg.SpeciesIndex(species)]++
+	}
+
+	env.countSpecies()
+	return env, nil
+}
@@ -0,0 +1,71 @@
+package environment
+
+import (
+	"Prey_Predator_MAS/config"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// captured is the snapshot of e without the wall clock time, which differs between two
+// environments running the same ticks.
+func captured(e *Environment) snapshot {
+	snap := e.Capture().snap
+	snap.ElapsedMs = 0
+	return snap
+}
+
+// TestSnapshotRoundTrip checks that a loaded environment is the saved one and then runs the
+// exact same ticks.
+func TestSnapshotRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *config.Config)
+	}{
+		{name: "default", change: func(cfg *config.Config) {}},
+		{name: "recurrent self-adaptive with signal", change: func(cfg *config.Config) {
+			cfg.Outputs = []string{config.OUTPUT_SIGNAL}
+			cfg.OutputNeuronNumber = config.OUTPUT_NEURON_NUMBER + 1
+			cfg.RecurrentConnections = true
+			cfg.SelfAdaptiveMutation = true
+			cfg.StartMutationNumber = 20
+		}},
+		{name: "sexual reproduction", change: func(cfg *config.Config) { cfg.SexualReproduction = true }},
+		{name: "vegetation", change: func(cfg *config.Config) { cfg.Vegetation.Enabled = true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.GetDefaultConfig()
+			cfg.NumAgents = 200
+			tt.change(&cfg)
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			saved := NewEnvironment(cfg)
+			for i := 0; i < 20; i++ {
+				saved.Step()
+			}
+
+			var buf bytes.Buffer
+			if err := saved.Save(&buf); err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := Load(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(captured(saved), captured(loaded)) {
+				t.Fatal("the loaded environment differs from the saved one")
+			}
+
+			for i := 0; i < 20; i++ {
+				saved.Step()
+				loaded.Step()
+			}
+			if !reflect.DeepEqual(captured(saved), captured(loaded)) {
+				t.Errorf("the loaded environment diverged by tick %d", saved.TickCounter)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package environment
+
+import (
+	"Prey_Predator_MAS/Brain"
+	"Prey_Predator_MAS/agents"
+)
+
+// Species groups the agents of a population, one of config.Config.Species, whose brains are
+// within the speciation threshold of its representative. IDs are never reused, a species
+// disappears when its last member dies.
+type Species struct {
+	ID uint32
+	// Population is the name of the config species of the members
+	Population string
+	// Founder is the id of the first agent of the species
+	Founder uint32
+	// Born is the tick the species appeared
+	Born uint64
+	Size int
+
+	representative *Brain.Brain
+}
+
+type SpeciesViewModel struct {
+	ID         uint32 `json:"id"`
+	Population string `json:"population"`
+	Founder    uint32 `json:"founder"`
+	Born       uint64 `json:"born"`
+	Age        uint64 `json:"age"`
+	Size       int    `json:"size"`
+}
+
+// Species returns the living species, oldest first.
+func (e *Environment) Species() []SpeciesViewModel {
+	e.stepLock.Lock()
+	defer e.stepLock.Unlock()
+
+	species := make([]SpeciesViewModel, 0, len(e.species))
+	for _, s := range e.species {
+		species = append(species, SpeciesViewModel{
+			ID:         s.ID,
+			Population: s.Population,
+			Founder:    s.Founder,
+			Born:       s.Born,
+			Age:        e.TickCounter - s.Born,
+			Size:       s.Size,
+		})
+	}
+	return species
+}
+
+// assignSpecies puts the agent in the first compatible species, trying the species hint (its
+// parent's) first, or founds a new species.
+func (e *Environment) assignSpecies(agent *agents.Agent, hint uint32) {
+	if s := e.speciesByID[hint]; s != nil && s.Population == agent.Species.Name && e.compatible(agent, s) {
+		agent.SpeciesID = s.ID
+		return
+	}
+	for _, s := range e.species {
+		if s.Population == agent.Species.Name && e.compatible(agent, s) {
+			agent.SpeciesID = s.ID
+			return
+		}
+	}
+
+	e.speciesCounter++
+	s := &Species{
+		ID:             e.speciesCounter,
+		Population:     agent.Species.Name,
+		Founder:        agent.ID,
+		Born:           e.TickCounter,
+		representative: agent.Brain,
+	}
+	e.species = append(e.species, s)
+	e.speciesByID[s.ID] = s
+	agent.SpeciesID = s.ID
+}
+
+func (e *Environment) compatible(agent *agents.Agent, s *Species) bool {
+	return Brain.CompatibilityDistance(agent.Brain, s.representative, e.cfg.Speciation) < e.cfg.Speciation.Threshold
+}
+
+// updateSpecies runs after every tick. Every Speciation.Every ticks the oldest member of each
+// species becomes its representative and every agent is assigned again, so that species follow
+// the drift of their population.
+func (e *Environment) updateSpecies() {
+	if e.cfg.Speciation.Every > 0 && e.TickCounter%uint64(e.cfg.Speciation.Every) == 0 {
+		representatives := make(map[uint32]bool, len(e.species))
+		for _, agent := range e.Agents {
+			if s := e.speciesByID[agent.SpeciesID]; s != nil && !representatives[s.ID] {
+				representatives[s.ID] = true
+				s.representative = agent.Brain
+			}
+		}
+		for _, agent := range e.Agents {
+			e.assignSpecies(agent, agent.SpeciesID)
+		}
+	}
+	e.countSpecies()
+}
+
+// countSpecies counts the members of every species and forgets the extinct ones.
+func (e *Environment) countSpecies() {
+	for _, s := range e.species {
+		s.Size = 0
+	}
+	for _, agent := range e.Agents {
+		if s := e.speciesByID[agent.SpeciesID]; s != nil {
+			s.Size++
+		}
+	}
+
+	living := e.species[:0]
+	for _, s := range e.species {
+		if s.Size > 0 {
+			living = append(living, s)
+		} else {
+			delete(e.speciesByID, s.ID)
+		}
+	}
+	e.species = living
+}
@@ -1,19 +1,13 @@
 package environment
 
 import (
-	"Prey_Predator_MAS/config"
 	"github.com/quartercastle/vector"
 	"math/rand"
 )
 
-
-
-func generateRandomOffset(color string) vector.Vector {
-
-	if color == "red" {
-		return vector.Vector{rand.Float64() * config.AGENT_RADIUS * 10, rand.Float64() * config.AGENT_RADIUS * 10}
-
-	} else {
-		return vector.Vector{rand.Float64() * config.AGENT_RADIUS * 15, rand.Float64() * config.AGENT_RADIUS * 15}
-	}
-}
\ No newline at end of file
+// generateRandomOffset draws the offset of an offspring from its parent, up to spread agent
+// radii along each axis.
+func generateRandomOffset(spread int, agentRadius int, r *rand.Rand) vector.Vector {
+	radius := float64(agentRadius)
+	return vector.Vector{r.Float64() * radius * float64(spread), r.Float64() * radius * float64(spread)}
+}
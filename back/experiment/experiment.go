@@ -0,0 +1,268 @@
+// Package experiment runs parameter sweeps: every combination of a grid (or random sample) of
+// config values is simulated headlessly for each seed, in parallel, and summarized in one table.
+package experiment
+
+import (
+	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/simulation"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes a sweep. Keys are config keys as accepted by config.Config.Set,
+// e.g. "species.predator.mealEnergy" or "mutationRate.newConnectionRate".
+type Spec struct {
+	// Grid runs the cartesian product of every listed value
+	Grid map[string][]interface{} `json:"grid" yaml:"grid"`
+	// Random draws Samples uniform points in [min, max] for every key, after the grid values
+	Random *RandomSpec `json:"random,omitempty" yaml:"random"`
+	// Seeds are run for every parameter combination
+	Seeds []int64 `json:"seeds" yaml:"seeds"`
+	// Ticks is the maximum length of a run
+	Ticks uint64 `json:"ticks" yaml:"ticks"`
+	// SampleEvery is the population sampling period used to compute the outcomes
+	SampleEvery uint64 `json:"sampleEvery" yaml:"sampleEvery"`
+	// KeepRunningAfterExtinction disables the early stop when a species dies out
+	KeepRunningAfterExtinction bool `json:"keepRunningAfterExtinction" yaml:"keepRunningAfterExtinction"`
+}
+
+type RandomSpec struct {
+	Samples int                   `json:"samples" yaml:"samples"`
+	Seed    int64                 `json:"seed" yaml:"seed"`
+	Ranges  map[string][2]float64 `json:"ranges" yaml:"ranges"`
+}
+
+// Run is one simulation of the sweep.
+type Run struct {
+	Index  int
+	Params map[string]string
+	Seed   int64
+	Config config.Config
+}
+
+// Outcome is one row of the results table.
+type Outcome struct {
+	Run
+	Err               error
+	Ticks             uint64
+	ExtinctionTick    uint64
+	ExtinctSpecies    string
+	OscillationPeriod float64
+	MaxGeneration     int
+	// MeanPopulations holds the mean number of agents of each species over the samples, by name
+	MeanPopulations map[string]float64
+}
+
+// LoadSpec reads a JSON or YAML sweep description.
+func LoadSpec(path string) (Spec, error) {
+	spec := Spec{
+		Seeds:       []int64{config.SEED},
+		Ticks:       10000,
+		SampleEvery: 10,
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &spec)
+	default:
+		err = json.Unmarshal(data, &spec)
+	}
+	if err != nil {
+		return spec, fmt.Errorf("spec %s: %w", path, err)
+	}
+	if len(spec.Seeds) == 0 {
+		return spec, fmt.Errorf("spec %s: no seeds", path)
+	}
+	if spec.SampleEvery == 0 {
+		spec.SampleEvery = 1
+	}
+	return spec, nil
+}
+
+// Keys returns the swept config keys in a stable order.
+func (s Spec) Keys() []string {
+	keys := make([]string, 0, len(s.Grid))
+	for key := range s.Grid {
+		keys = append(keys, key)
+	}
+	if s.Random != nil {
+		for key := range s.Random.Ranges {
+			if _, ok := s.Grid[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Runs expands the spec on top of base into the list of simulations to perform.
+func (s Spec) Runs(base config.Config) ([]Run, error) {
+	points := []map[string]string{{}}
+
+	gridKeys := make([]string, 0, len(s.Grid))
+	for key := range s.Grid {
+		gridKeys = append(gridKeys, key)
+	}
+	sort.Strings(gridKeys)
+	for _, key := range gridKeys {
+		expanded := make([]map[string]string, 0, len(points)*len(s.Grid[key]))
+		for _, point := range points {
+			for _, value := range s.Grid[key] {
+				next := clone(point)
+				next[key] = fmt.Sprint(value)
+				expanded = append(expanded, next)
+			}
+		}
+		points = expanded
+	}
+
+	if s.Random != nil && s.Random.Samples > 0 {
+		r := rand.New(rand.NewSource(s.Random.Seed))
+		rangeKeys := make([]string, 0, len(s.Random.Ranges))
+		for key := range s.Random.Ranges {
+			rangeKeys = append(rangeKeys, key)
+		}
+		sort.Strings(rangeKeys)
+
+		sampled := make([]map[string]string, 0, len(points)*s.Random.Samples)
+		for _, point := range points {
+			for i := 0; i < s.Random.Samples; i++ {
+				next := clone(point)
+				for _, key := range rangeKeys {
+					bounds := s.Random.Ranges[key]
+					next[key] = fmt.Sprintf("%.6g", bounds[0]+r.Float64()*(bounds[1]-bounds[0]))
+				}
+				sampled = append(sampled, next)
+			}
+		}
+		points = sampled
+	}
+
+	runs := make([]Run, 0, len(points)*len(s.Seeds))
+	for _, point := range points {
+		for _, seed := range s.Seeds {
+			cfg := base
+			for key, value := range point {
+				if err := setValue(&cfg, key, value); err != nil {
+					return nil, err
+				}
+			}
+			cfg.Seed = seed
+			if err := cfg.Validate(); err != nil {
+				return nil, fmt.Errorf("%v: %w", point, err)
+			}
+			runs = append(runs, Run{
+				Index:  len(runs),
+				Params: point,
+				Seed:   seed,
+				Config: cfg,
+			})
+		}
+	}
+	return runs, nil
+}
+
+// setValue accepts floats for integer keys so that random samples can be applied.
+func setValue(cfg *config.Config, key, value string) error {
+	if err := cfg.Set(key, value); err == nil {
+		return nil
+	}
+	var number float64
+	if _, err := fmt.Sscan(value, &number); err != nil {
+		return cfg.Set(key, value)
+	}
+	return cfg.SetNumber(key, number)
+}
+
+func clone(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// Execute runs every simulation on up to workers goroutines. Outcomes are returned in run
+// order whatever the completion order, done is called after each run if not nil.
+func (s Spec) Execute(runs []Run, workers int, done func(Outcome)) []Outcome {
+	outcomes := make([]Outcome, len(runs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var doneLock sync.Mutex
+
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				outcomes[index] = s.execute(runs[index])
+				if done != nil {
+					doneLock.Lock()
+					done(outcomes[index])
+					doneLock.Unlock()
+				}
+			}
+		}()
+	}
+
+	for index := range runs {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+	return outcomes
+}
+
+func (s Spec) execute(run Run) (outcome Outcome) {
+	outcome.Run = run
+	defer func() {
+		if r := recover(); r != nil {
+			outcome.Err = fmt.Errorf("run %d panicked: %v", run.Index, r)
+		}
+	}()
+
+	result := simulation.NewSimulation(run.Config).Run(simulation.RunOptions{
+		MaxTicks:         s.Ticks,
+		StopOnExtinction: !s.KeepRunningAfterExtinction,
+		SampleEvery:      s.SampleEvery,
+	})
+
+	outcome.Ticks = result.Ticks
+	outcome.MaxGeneration = result.MaxGeneration
+	outcome.ExtinctSpecies = result.ExtinctSpecies
+	if result.ExtinctSpecies != "" {
+		outcome.ExtinctionTick = result.Ticks
+	}
+
+	// the oscillation is measured on the first species hunting agents, the predators by default
+	oscillating := max(0, slices.IndexFunc(run.Config.Species, func(s config.Species) bool { return s.Hunts() }))
+	outcome.MeanPopulations = make(map[string]float64, len(run.Config.Species))
+	for i, species := range run.Config.Species {
+		series := make([]float64, len(result.Series))
+		for j, sample := range result.Series {
+			series[j] = float64(sample.Populations[i])
+		}
+		outcome.MeanPopulations[species.Name] = mean(series)
+		if i == oscillating {
+			outcome.OscillationPeriod = OscillationPeriod(series) * float64(s.SampleEvery)
+		}
+	}
+	return outcome
+}
@@ -0,0 +1,131 @@
+package experiment
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// OscillationPeriod estimates the dominant period of a series, in samples, as the lag of the
+// highest autocorrelation peak after the first zero crossing. It returns 0 when the series
+// does not oscillate.
+func OscillationPeriod(series []float64) float64 {
+	n := len(series)
+	if n < 4 {
+		return 0
+	}
+
+	m := mean(series)
+	centered := make([]float64, n)
+	variance := 0.0
+	for i, v := range series {
+		centered[i] = v - m
+		variance += centered[i] * centered[i]
+	}
+	if variance == 0 {
+		return 0
+	}
+
+	autocorrelation := func(lag int) float64 {
+		sum := 0.0
+		for i := 0; i+lag < n; i++ {
+			sum += centered[i] * centered[i+lag]
+		}
+		return sum / variance
+	}
+
+	// only lags seen at least twice in the series are meaningful
+	maxLag := n / 2
+	crossed := false
+	bestLag, best := 0, 0.0
+	previous := autocorrelation(1)
+	for lag := 2; lag < maxLag; lag++ {
+		current := autocorrelation(lag)
+		if !crossed {
+			crossed = current < 0
+		} else if current < previous && previous > best {
+			// previous lag was a local maximum
+			bestLag, best = lag-1, previous
+		}
+		previous = current
+	}
+	return float64(bestLag)
+}
+
+// WriteCSV writes one row per outcome, the swept keys come first. The mean population of every
+// species of the runs gets its own column, e.g. "meanPrey".
+func WriteCSV(w io.Writer, keys []string, outcomes []Outcome) error {
+	writer := csv.NewWriter(w)
+
+	var species []string
+	for _, outcome := range outcomes {
+		for _, s := range outcome.Config.Species {
+			if !slices.Contains(species, s.Name) {
+				species = append(species, s.Name)
+			}
+		}
+	}
+
+	header := append([]string{"run", "seed"}, keys...)
+	header = append(header, "ticks", "extinctionTick", "extinctSpecies")
+	for _, name := range species {
+		header = append(header, "mean"+strings.ToUpper(name[:1])+name[1:])
+	}
+	header = append(header, "oscillationPeriod", "maxGeneration", "error")
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, outcome := range outcomes {
+		row := []string{strconv.Itoa(outcome.Index), strconv.FormatInt(outcome.Seed, 10)}
+		for _, key := range keys {
+			row = append(row, outcome.Params[key])
+		}
+		errText := ""
+		if outcome.Err != nil {
+			errText = outcome.Err.Error()
+		}
+		extinctionTick := ""
+		if outcome.ExtinctSpecies != "" {
+			extinctionTick = strconv.FormatUint(outcome.ExtinctionTick, 10)
+		}
+		row = append(row,
+			strconv.FormatUint(outcome.Ticks, 10),
+			extinctionTick,
+			outcome.ExtinctSpecies,
+		)
+		for _, name := range species {
+			// a species absent from the run, or a failed run, leaves the column empty
+			meanPopulation := ""
+			if value, ok := outcome.MeanPopulations[name]; ok {
+				meanPopulation = fmt.Sprintf("%.2f", value)
+			}
+			row = append(row, meanPopulation)
+		}
+		row = append(row,
+			fmt.Sprintf("%.0f", outcome.OscillationPeriod),
+			strconv.Itoa(outcome.MaxGeneration),
+			errText,
+		)
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -2,38 +2,35 @@ package fixedgrid
 
 import (
 	"Prey_Predator_MAS/agents"
-	"Prey_Predator_MAS/config"
-	"fmt"
 )
 
 type AgentStack struct {
-	elements 					[config.APPROXIMATE_MAX_AGENT_PER_CELL]*agents.Agent
-	size	 					int
+	elements []*agents.Agent
 }
 
-func NewAgentStack() *AgentStack {
+// NewAgentStack creates an empty stack with room for capacity agents, it grows when needed.
+func NewAgentStack(capacity int) *AgentStack {
 	return &AgentStack{
-		elements:	[config.APPROXIMATE_MAX_AGENT_PER_CELL]*agents.Agent{},
-		size:     	0,
+		elements: make([]*agents.Agent, 0, capacity),
 	}
 }
 
 func (s *AgentStack) Push(agent *agents.Agent) {
-	if s.size >= config.APPROXIMATE_MAX_AGENT_PER_CELL {
-		fmt.Println("ERROR: AgentStack is full, need to increase APPROXIMATE_MAX_AGENT_PER_CELL")
-		return
-	}
-	s.elements[s.size] = agent
-	s.size++
+	s.elements = append(s.elements, agent)
 }
 
 func (s *AgentStack) Remove(agent *agents.Agent) {
-	for i := 0; i < s.size; i++ {
+	last := len(s.elements) - 1
+	for i := 0; i <= last; i++ {
 		if s.elements[i] == agent {
-			s.elements[i] = s.elements[s.size-1]
-			s.elements[s.size-1] = nil
-			s.size--
+			s.elements[i] = s.elements[last]
+			s.elements[last] = nil
+			s.elements = s.elements[:last]
 			return
 		}
 	}
 }
+
+func (s *AgentStack) Len() int {
+	return len(s.elements)
+}
@@ -10,51 +10,91 @@ import (
 
 var _ agents.GridAgentProvider = (*FixedGrid)(nil)
 
+// FixedGrid buckets agents into square cells of cellSize, indexed by [x][y] cell coordinates.
 type FixedGrid struct {
-	rows, cols int
-	cellSize   int
-	AgentsMap  [config.HEIGHT / config.CELL_SIZE][config.WIDTH / config.CELL_SIZE]AgentStack
-	GridMutex  [config.HEIGHT / config.CELL_SIZE][config.WIDTH / config.CELL_SIZE]sync.Mutex
-}
-
-func NewFixedGrid() FixedGrid {
-	fg := FixedGrid{
-		rows:      config.HEIGHT / config.CELL_SIZE,
-		cols:      config.WIDTH / config.CELL_SIZE,
-		cellSize:  config.CELL_SIZE,
-		AgentsMap: [config.HEIGHT / config.CELL_SIZE][config.WIDTH / config.CELL_SIZE]AgentStack{},
-		GridMutex: [config.HEIGHT / config.CELL_SIZE][config.WIDTH / config.CELL_SIZE]sync.Mutex{},
+	cols, rows    int
+	cellSize      int
+	width, height int
+	AgentsMap     [][]AgentStack
+	GridMutex     [][]sync.Mutex
+}
+
+func NewFixedGrid(cfg config.Config) *FixedGrid {
+	cols := (cfg.Width + cfg.CellSize - 1) / cfg.CellSize
+	rows := (cfg.Height + cfg.CellSize - 1) / cfg.CellSize
+
+	fg := &FixedGrid{
+		cols:      cols,
+		rows:      rows,
+		cellSize:  cfg.CellSize,
+		width:     cfg.Width,
+		height:    cfg.Height,
+		AgentsMap: make([][]AgentStack, cols),
+		GridMutex: make([][]sync.Mutex, cols),
 	}
 
-	for i := 0; i < config.HEIGHT/config.CELL_SIZE; i++ {
-		for j := 0; j < config.WIDTH/config.CELL_SIZE; j++ {
-			fg.AgentsMap[i][j] = *NewAgentStack()
+	for i := 0; i < cols; i++ {
+		fg.AgentsMap[i] = make([]AgentStack, rows)
+		fg.GridMutex[i] = make([]sync.Mutex, rows)
+		for j := 0; j < rows; j++ {
+			fg.AgentsMap[i][j] = *NewAgentStack(cfg.CellCapacity)
 		}
 	}
 
-	// copy is ok here
 	return fg
 }
 
+// CellOf returns the cell coordinates containing the given world position.
+func (fg *FixedGrid) CellOf(x, y float64) (uint32, uint32) {
+	col, row := uint32(x/float64(fg.cellSize)), uint32(y/float64(fg.cellSize))
+	if col >= uint32(fg.cols) {
+		col = uint32(fg.cols - 1)
+	}
+	if row >= uint32(fg.rows) {
+		row = uint32(fg.rows - 1)
+	}
+	return col, row
+}
+
+func (fg *FixedGrid) CellSize() int {
+	return fg.cellSize
+}
+
+func (fg *FixedGrid) Bounds() (width, height int) {
+	return fg.width, fg.height
+}
+
 func (fg *FixedGrid) AddAgent(agent *agents.Agent) {
-	row, col := agents.GetGridCell(agent.Position.X(), agent.Position.Y())
-	fg.GridMutex[row][col].Lock()
-	defer fg.GridMutex[row][col].Unlock()
-	fg.AgentsMap[row][col].Push(agent)
+	col, row := fg.CellOf(agent.Position.X(), agent.Position.Y())
+	fg.GridMutex[col][row].Lock()
+	defer fg.GridMutex[col][row].Unlock()
+	fg.AgentsMap[col][row].Push(agent)
 }
 
 func (fg *FixedGrid) RemoveAgent(agent *agents.Agent, oldPosition vector.Vector) {
-	row, col := agents.GetGridCell(oldPosition.X(), oldPosition.Y())
-	fg.GridMutex[row][col].Lock()
-	defer fg.GridMutex[row][col].Unlock()
-	fg.AgentsMap[row][col].Remove(agent)
+	col, row := fg.CellOf(oldPosition.X(), oldPosition.Y())
+	fg.GridMutex[col][row].Lock()
+	defer fg.GridMutex[col][row].Unlock()
+	fg.AgentsMap[col][row].Remove(agent)
 }
-func (fg *FixedGrid) GetAgentsInCell(row, col uint32) []*agents.Agent {
-	if row >= uint32(fg.rows) || col >= uint32(fg.cols) || row < 0 || col < 0 {
+
+// MoveAgent updates the agent's bucket if it left the cell containing oldPosition.
+func (fg *FixedGrid) MoveAgent(agent *agents.Agent, oldPosition vector.Vector) {
+	oldCol, oldRow := fg.CellOf(oldPosition.X(), oldPosition.Y())
+	col, row := fg.CellOf(agent.Position.X(), agent.Position.Y())
+	if oldCol == col && oldRow == row {
+		return
+	}
+	fg.RemoveAgent(agent, oldPosition)
+	fg.AddAgent(agent)
+}
+
+func (fg *FixedGrid) GetAgentsInCell(col, row uint32) []*agents.Agent {
+	if col >= uint32(fg.cols) || row >= uint32(fg.rows) {
 		return nil
 	}
 
-	fg.GridMutex[row][col].Lock()
-	defer fg.GridMutex[row][col].Unlock()
-	return fg.AgentsMap[row][col].elements[:fg.AgentsMap[row][col].size]
+	fg.GridMutex[col][row].Lock()
+	defer fg.GridMutex[col][row].Unlock()
+	return fg.AgentsMap[col][row].elements
 }
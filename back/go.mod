@@ -3,8 +3,10 @@ module Prey_Predator_MAS
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/gorilla/websocket v1.5.1
 	github.com/quartercastle/vector v0.2.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"Prey_Predator_MAS/checkpoint"
+	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/simulation"
 	"Prey_Predator_MAS/webserver"
-	"math/rand"
+	"flag"
+	"log"
 	_ "net/http/pprof"
 )
 
@@ -10,11 +14,29 @@ import (
 //import "github.com/pkg/profile"
 
 func main() {
-	rand.Seed(100000)
+	loadConfig := config.RegisterFlags(flag.CommandLine)
+	loadCheckpoints := checkpoint.RegisterFlags(flag.CommandLine)
+	address := flag.String("address", "localhost", "address the web server listens on")
+	port := flag.String("port", "8080", "port the web server listens on")
+	flag.Parse()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	checkpoints, err := loadCheckpoints()
+	if err != nil {
+		log.Fatal(err)
+	}
+	sim, err := simulation.Resume(cfg, checkpoints)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	//defer profile.Start(profile.ProfilePath(".")).Stop()
 	// go func() {
 	// 	http.ListenAndServe("localhost:6060", nil)
 	// }()
-	server := webserver.NewWebServer("localhost", "8080")
+	server := webserver.NewWebServer(*address, *port, sim)
 	server.Start()
 }
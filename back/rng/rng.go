@@ -0,0 +1,58 @@
+package rng
+
+import (
+	"math/rand"
+)
+
+// Source is a splitmix64 generator. Its whole state is a single word, so every agent can own
+// one and it can be saved and restored exactly.
+type Source struct {
+	state uint64
+}
+
+var _ rand.Source64 = (*Source)(nil)
+
+func (s *Source) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *Source) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *Source) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+// Rand is a math/rand generator whose state can be read back and which can be split into
+// independent sub-streams.
+type Rand struct {
+	*rand.Rand
+	src *Source
+}
+
+func New(seed int64) *Rand {
+	src := &Source{state: uint64(seed)}
+	return &Rand{
+		Rand: rand.New(src),
+		src:  src,
+	}
+}
+
+// Split derives a new stream from the next value of r. Splitting in the same order always
+// gives the same streams.
+func (r *Rand) Split() *Rand {
+	return New(int64(r.src.Uint64()))
+}
+
+func (r *Rand) State() uint64 {
+	return r.src.state
+}
+
+func (r *Rand) SetState(state uint64) {
+	r.src.state = state
+}
@@ -0,0 +1,87 @@
+package simulation
+
+import (
+	"Prey_Predator_MAS/Brain"
+	"slices"
+	"time"
+)
+
+// RunOptions controls a headless run.
+type RunOptions struct {
+	// MaxTicks stops the run after this many ticks, 0 means no limit
+	MaxTicks uint64
+	// StopOnExtinction stops the run as soon as one species has no agent left
+	StopOnExtinction bool
+	// SampleEvery records the populations every SampleEvery ticks, 0 disables the series
+	SampleEvery uint64
+	// OnSample, if set, is called with every recorded sample instead of keeping them in
+	// RunResult.Series, so that long runs can stream them
+	OnSample func(sample PopulationSample)
+}
+
+type PopulationSample struct {
+	Tick uint64 `json:"tick"`
+	// Populations holds the number of agents of each species, indexed like config.Config.Species
+	Populations []int `json:"populations"`
+}
+
+// RunResult summarizes a headless run.
+type RunResult struct {
+	Ticks          uint64             `json:"ticks"`
+	Populations    []int              `json:"populations"` // indexed like config.Config.Species
+	ExtinctSpecies string             `json:"extinctSpecies,omitempty"`
+	MaxGeneration  int                `json:"maxGeneration"`
+	Elapsed        time.Duration      `json:"elapsed"`
+	Series         []PopulationSample `json:"series,omitempty"` // empty when RunOptions.OnSample is set
+	// MutationParams summarizes the evolved mutation parameters by species, in the self-adaptive mode
+	MutationParams map[string]Brain.MutationParamsSummary `json:"mutationParams,omitempty"`
+}
+
+// Run steps the environment as fast as possible, without waiting for any client, until
+// MaxTicks is reached or, if StopOnExtinction is set, until a species dies out.
+func (sim *Simulation) Run(opts RunOptions) RunResult {
+	env := sim.Environment()
+	start := time.Now()
+	result := RunResult{}
+
+	sample := func() {
+		s := PopulationSample{
+			Tick:        env.TickCounter,
+			Populations: slices.Clone(env.Populations),
+		}
+		if opts.OnSample != nil {
+			opts.OnSample(s)
+		} else {
+			result.Series = append(result.Series, s)
+		}
+	}
+
+	if opts.SampleEvery > 0 {
+		sample()
+	}
+
+	for ticks := uint64(0); opts.MaxTicks == 0 || ticks < opts.MaxTicks; ticks++ {
+		if opts.StopOnExtinction && slices.Contains(env.Populations, 0) {
+			break
+		}
+
+		env.Step()
+		sim.afterStep(env)
+
+		if opts.SampleEvery > 0 && env.TickCounter%opts.SampleEvery == 0 {
+			sample()
+		}
+	}
+
+	result.Ticks = env.TickCounter
+	result.Populations = slices.Clone(env.Populations)
+	result.MaxGeneration = env.MaxGeneration
+	result.Elapsed = time.Since(start)
+	if i := slices.Index(env.Populations, 0); i >= 0 {
+		result.ExtinctSpecies = env.Config().Species[i].Name
+	}
+	if env.Config().SelfAdaptiveMutation {
+		result.MutationParams = env.MutationParams()
+	}
+	return result
+}
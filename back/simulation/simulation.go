@@ -1,21 +1,84 @@
 package simulation
 
 import (
+	"Prey_Predator_MAS/checkpoint"
 	"Prey_Predator_MAS/config"
 	"Prey_Predator_MAS/environment"
+	"log"
+	"sync"
 )
 
 type Simulation struct {
-	Environment *environment.Environment
+	env *environment.Environment
+	// Checkpoints, if set, is given the environment after every tick
+	Checkpoints *checkpoint.Manager
+	lock        sync.Mutex
 }
 
-func NewSimulation() *Simulation {
-	config := config.GetDefaultConfig()
+func NewSimulation(config config.Config) *Simulation {
+	return FromEnvironment(environment.NewEnvironment(config))
+}
+
+// FromEnvironment wraps an existing environment, e.g. one restored from a snapshot.
+func FromEnvironment(env *environment.Environment) *Simulation {
 	return &Simulation{
-		Environment: environment.NewEnvironment(config.Width, config.Height, config.NumAgents, config.CellSize),
+		env: env,
+	}
+}
+
+// Resume returns a simulation started from the newest valid checkpoint of checkpoints, or a
+// new one built from cfg if there is none. It fails when checkpoints exist but none loads.
+func Resume(cfg config.Config, checkpoints *checkpoint.Manager) (*Simulation, error) {
+	var sim *Simulation
+	if checkpoints != nil {
+		env, path, err := checkpoints.LoadLatest()
+		if err != nil {
+			return nil, err
+		}
+		if env != nil {
+			log.Printf("resuming from %s at tick %d", path, env.TickCounter)
+			sim = FromEnvironment(env)
+		}
+	}
+	if sim == nil {
+		sim = NewSimulation(cfg)
 	}
+	sim.Checkpoints = checkpoints
+	return sim, nil
+}
+
+// Environment returns the running environment, Replace swaps it.
+func (sim *Simulation) Environment() *environment.Environment {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	return sim.env
 }
 
 func (sim *Simulation) Start() {
-	sim.Environment.Start()
+	sim.lock.Lock()
+	env := sim.env
+	env.AfterStep = sim.afterStep
+	sim.lock.Unlock()
+	env.Start()
+}
+
+// Replace stops the running environment and starts env in its place.
+func (sim *Simulation) Replace(env *environment.Environment) {
+	sim.lock.Lock()
+	old := sim.env
+	sim.env = env
+	env.AfterStep = sim.afterStep
+	sim.lock.Unlock()
+
+	old.Stop()
+	go env.Start()
+}
+
+func (sim *Simulation) afterStep(env *environment.Environment) {
+	if sim.Checkpoints == nil {
+		return
+	}
+	if err := sim.Checkpoints.Maybe(env); err != nil {
+		log.Printf("checkpoint failed: %v", err)
+	}
 }
@@ -0,0 +1,186 @@
+package terrain
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kind is what covers a cell of the map.
+type Kind uint8
+
+const (
+	// OPEN ground does nothing
+	OPEN Kind = iota
+	// WALL stops agents and rays
+	WALL
+	// TREE stops rays, agents walk under it
+	TREE
+	// WATER slows agents down
+	WATER
+	// SAFE zones are closed to the species hunting other agents
+	SAFE
+)
+
+// names are the kinds in the JSON polygons, by value.
+var names = []string{"open", "wall", "tree", "water", "safe"}
+
+// palette is the color of each kind in a PNG map, by value.
+var palette = []color.RGBA{
+	{255, 255, 255, 255},
+	{0, 0, 0, 255},
+	{0, 128, 0, 255},
+	{0, 0, 255, 255},
+	{255, 255, 0, 255},
+}
+
+// Map covers the world with square cells of CellSize, row by row. A nil map is open ground
+// everywhere.
+type Map struct {
+	CellSize int `json:"cellSize"`
+	Cols     int `json:"cols"`
+	Rows     int `json:"rows"`
+	// Cells holds the kind of every cell, sent to the clients as base64
+	Cells []Kind `json:"cells"`
+}
+
+// Polygon is an area of the map in world coordinates, as found in JSON maps:
+//
+//	{"polygons": [{"kind": "wall", "points": [[0, 0], [100, 0], [100, 8], [0, 8]]}]}
+type Polygon struct {
+	Kind   string       `json:"kind"`
+	Points [][2]float64 `json:"points"`
+}
+
+// Load reads the map of a width x height world, cut into cells of cellSize, from a PNG image
+// stretched over the world or from a JSON list of polygons. Later polygons cover earlier ones.
+func Load(path string, width, height, cellSize int) (*Map, error) {
+	if cellSize <= 0 {
+		return nil, fmt.Errorf("terrain cell size must be positive, got %d", cellSize)
+	}
+	m := &Map{CellSize: cellSize, Cols: (width + cellSize - 1) / cellSize, Rows: (height + cellSize - 1) / cellSize}
+	m.Cells = make([]Kind, m.Cols*m.Rows)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		img, _, err := image.Decode(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		m.paint(img, width, height)
+	case ".json":
+		var polygons struct {
+			Polygons []Polygon `json:"polygons"`
+		}
+		if err := json.NewDecoder(file).Decode(&polygons); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := m.fill(polygons.Polygons); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported terrain map %s, expected a .png or .json file", path)
+	}
+	return m, nil
+}
+
+// paint gives every cell the kind whose palette color is the nearest to the pixel under its
+// center. Transparent pixels are open ground.
+func (m *Map) paint(img image.Image, width, height int) {
+	b := img.Bounds()
+	for row := 0; row < m.Rows; row++ {
+		for col := 0; col < m.Cols; col++ {
+			x := b.Min.X + int((float64(col)+0.5)*float64(m.CellSize)*float64(b.Dx())/float64(width))
+			y := b.Min.Y + int((float64(row)+0.5)*float64(m.CellSize)*float64(b.Dy())/float64(height))
+			m.Cells[row*m.Cols+col] = nearest(color.RGBAModel.Convert(img.At(min(x, b.Max.X-1), min(y, b.Max.Y-1))).(color.RGBA))
+		}
+	}
+}
+
+func nearest(c color.RGBA) Kind {
+	if c.A < 128 {
+		return OPEN
+	}
+	kind, best := OPEN, math.Inf(1)
+	for k, p := range palette {
+		dr, dg, db := float64(c.R)-float64(p.R), float64(c.G)-float64(p.G), float64(c.B)-float64(p.B)
+		if d := dr*dr + dg*dg + db*db; d < best {
+			kind, best = Kind(k), d
+		}
+	}
+	return kind
+}
+
+// fill gives the kind of the polygons to the cells whose center they contain.
+func (m *Map) fill(polygons []Polygon) error {
+	for i, polygon := range polygons {
+		kind := -1
+		for k, name := range names {
+			if name == polygon.Kind {
+				kind = k
+			}
+		}
+		if kind < 0 {
+			return fmt.Errorf("polygon %d: unknown kind %q, expected one of %s", i, polygon.Kind, strings.Join(names, ", "))
+		}
+		if len(polygon.Points) < 3 {
+			return fmt.Errorf("polygon %d: %d points, at least 3 are needed", i, len(polygon.Points))
+		}
+		for row := 0; row < m.Rows; row++ {
+			for col := 0; col < m.Cols; col++ {
+				x, y := (float64(col)+0.5)*float64(m.CellSize), (float64(row)+0.5)*float64(m.CellSize)
+				if contains(polygon.Points, x, y) {
+					m.Cells[row*m.Cols+col] = Kind(kind)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// contains tells whether x, y is inside points, by the even-odd rule.
+func contains(points [][2]float64, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		a, b := points[i], points[j]
+		if (a[1] > y) != (b[1] > y) && x < (b[0]-a[0])*(y-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// At returns the kind of the cell holding x, y, positions outside of the map being taken from
+// its nearest edge.
+func (m *Map) At(x, y float64) Kind {
+	if m == nil {
+		return OPEN
+	}
+	col := min(max(int(math.Floor(x/float64(m.CellSize))), 0), m.Cols-1)
+	row := min(max(int(math.Floor(y/float64(m.CellSize))), 0), m.Rows-1)
+	return m.Cells[row*m.Cols+col]
+}
+
+// Blocks tells whether an agent, hunting other agents or not, cannot stand at x, y.
+func (m *Map) Blocks(x, y float64, hunter bool) bool {
+	kind := m.At(x, y)
+	return kind == WALL || kind == SAFE && hunter
+}
+
+// BlocksRays tells whether rays stop at x, y.
+func (m *Map) BlocksRays(x, y float64) bool {
+	kind := m.At(x, y)
+	return kind == WALL || kind == TREE
+}
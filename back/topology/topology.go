@@ -0,0 +1,164 @@
+package topology
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/quartercastle/vector"
+)
+
+const (
+	TORUS   = "torus"
+	WALLS   = "walls"
+	REFLECT = "reflect"
+)
+
+// Topology is what happens at the edges of a width x height world. Movement, perception,
+// collisions and spawning all go through it, so that agents near an edge see, touch and mate
+// with the agents just across it on a torus, and nothing beyond it otherwise.
+type Topology interface {
+	// Move adds velocity to position and brings the result back into the world. It returns the
+	// velocity the agent keeps, turned back when it bounced.
+	Move(position, velocity vector.Vector) (vector.Vector, vector.Vector)
+	// Place brings a position computed from another one, e.g. a newborn next to its parent,
+	// back into the world.
+	Place(x, y float64) (float64, float64)
+	// Delta is the shortest displacement from (x1, y1) to (x2, y2).
+	Delta(x1, y1, x2, y2 float64) (dx, dy float64)
+	// Cell returns the grid cell at col, row, out of cells of cellSize, and false if it lies
+	// outside of the world.
+	Cell(col, row, cellSize int) (int, int, bool)
+}
+
+// New returns the topology named name, one of TORUS, WALLS and REFLECT.
+func New(name string, width, height int) (Topology, error) {
+	b := bounds{width: float64(width), height: float64(height), intWidth: width, intHeight: height}
+	switch name {
+	case TORUS:
+		return &torus{b}, nil
+	case WALLS:
+		return &walls{b}, nil
+	case REFLECT:
+		return &reflecting{b}, nil
+	}
+	return nil, fmt.Errorf("unknown topology %q, expected %s, %s or %s", name, TORUS, WALLS, REFLECT)
+}
+
+type bounds struct {
+	width, height       float64
+	intWidth, intHeight int
+}
+
+// cell returns the cell at col, row if it is inside the world.
+func (b *bounds) cell(col, row, cellSize int) (int, int, bool) {
+	cols := (b.intWidth + cellSize - 1) / cellSize
+	rows := (b.intHeight + cellSize - 1) / cellSize
+	return col, row, col >= 0 && row >= 0 && col < cols && row < rows
+}
+
+// torus wraps every edge to the opposite one.
+type torus struct{ bounds }
+
+func (t *torus) Move(position, velocity vector.Vector) (vector.Vector, vector.Vector) {
+	position = position.Add(velocity)
+	position[0], position[1] = t.Place(position[0], position[1])
+	return position, velocity
+}
+
+func (t *torus) Place(x, y float64) (float64, float64) {
+	return wrap(x, t.width), wrap(y, t.height)
+}
+
+func (t *torus) Delta(x1, y1, x2, y2 float64) (dx, dy float64) {
+	return shortest(x2-x1, t.width), shortest(y2-y1, t.height)
+}
+
+func (t *torus) Cell(col, row, cellSize int) (int, int, bool) {
+	if col >= 0 && row >= 0 && col*cellSize < t.intWidth && row*cellSize < t.intHeight {
+		return col, row, true
+	}
+	cols := (t.intWidth + cellSize - 1) / cellSize
+	rows := (t.intHeight + cellSize - 1) / cellSize
+	return (col%cols + cols) % cols, (row%rows + rows) % rows, true
+}
+
+// wrap returns value modulo size, in [0, size[.
+func wrap(value, size float64) float64 {
+	value -= size * math.Floor(value/size)
+	if value >= size {
+		// rounding of a tiny negative value
+		return 0
+	}
+	return value
+}
+
+// shortest returns the displacement equivalent to d modulo size that is the closest to 0.
+func shortest(d, size float64) float64 {
+	if d > size/2 {
+		return d - size
+	} else if d < -size/2 {
+		return d + size
+	}
+	return d
+}
+
+// walls stop agents at the edges, they keep pushing against them until they turn.
+type walls struct{ bounds }
+
+func (w *walls) Move(position, velocity vector.Vector) (vector.Vector, vector.Vector) {
+	position = position.Add(velocity)
+	position[0], position[1] = w.Place(position[0], position[1])
+	return position, velocity
+}
+
+func (w *walls) Place(x, y float64) (float64, float64) {
+	return clamp(x, w.width-1), clamp(y, w.height-1)
+}
+
+func (w *walls) Delta(x1, y1, x2, y2 float64) (dx, dy float64) {
+	return x2 - x1, y2 - y1
+}
+
+func (w *walls) Cell(col, row, cellSize int) (int, int, bool) {
+	return w.cell(col, row, cellSize)
+}
+
+func clamp(value, max float64) float64 {
+	return math.Max(0, math.Min(max, value))
+}
+
+// reflecting bounces agents off the edges, mirroring their position and velocity.
+type reflecting struct{ bounds }
+
+func (r *reflecting) Move(position, velocity vector.Vector) (vector.Vector, vector.Vector) {
+	position = position.Add(velocity)
+	velocity = velocity.Clone()
+	for axis, max := range []float64{r.width - 1, r.height - 1} {
+		if position[axis] < 0 || position[axis] > max {
+			position[axis] = mirror(position[axis], max)
+			velocity[axis] = -velocity[axis]
+		}
+	}
+	return position, velocity
+}
+
+func (r *reflecting) Place(x, y float64) (float64, float64) {
+	return mirror(x, r.width-1), mirror(y, r.height-1)
+}
+
+func (r *reflecting) Delta(x1, y1, x2, y2 float64) (dx, dy float64) {
+	return x2 - x1, y2 - y1
+}
+
+func (r *reflecting) Cell(col, row, cellSize int) (int, int, bool) {
+	return r.cell(col, row, cellSize)
+}
+
+// mirror folds value into [0, max] as if it bounced off both ends.
+func mirror(value, max float64) float64 {
+	value = wrap(value, 2*max)
+	if value > max {
+		return 2*max - value
+	}
+	return value
+}
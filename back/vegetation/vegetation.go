@@ -0,0 +1,128 @@
+package vegetation
+
+import (
+	"Prey_Predator_MAS/config"
+	"fmt"
+	"math"
+)
+
+// Field is the vegetation the prey live off, an amount of energy in every square cell of
+// CellSize, row by row. It grows back towards a capacity that can change with the seasons. A nil
+// field has no vegetation at all.
+type Field struct {
+	CellSize int
+	Cols     int
+	Rows     int
+	Amounts  []float64
+
+	cfg config.Vegetation
+}
+
+// Heatmap is a coarse view of a field for the clients, every cell averaging Scale x Scale cells
+// of the field.
+type Heatmap struct {
+	CellSize int `json:"cellSize"`
+	Cols     int `json:"cols"`
+	Rows     int `json:"rows"`
+	// Values are the amounts as fractions of the capacity, scaled to 0-255 and sent as base64
+	Values []byte `json:"values"`
+}
+
+// New returns a fully grown field covering a width x height world.
+func New(width, height int, cfg config.Vegetation) *Field {
+	f := &Field{
+		CellSize: cfg.CellSize,
+		Cols:     (width + cfg.CellSize - 1) / cfg.CellSize,
+		Rows:     (height + cfg.CellSize - 1) / cfg.CellSize,
+		cfg:      cfg,
+	}
+	f.Amounts = make([]float64, f.Cols*f.Rows)
+	for i := range f.Amounts {
+		f.Amounts[i] = cfg.Capacity
+	}
+	return f
+}
+
+// Restore replaces the amounts of the field with saved ones.
+func (f *Field) Restore(amounts []float64) error {
+	if len(amounts) != len(f.Amounts) {
+		return fmt.Errorf("vegetation has %d cells, expected %d", len(amounts), len(f.Amounts))
+	}
+	copy(f.Amounts, amounts)
+	return nil
+}
+
+// index returns the cell holding x, y, positions outside of the field being taken from its
+// nearest edge.
+func (f *Field) index(x, y float64) int {
+	col := min(max(int(math.Floor(x/float64(f.CellSize))), 0), f.Cols-1)
+	row := min(max(int(math.Floor(y/float64(f.CellSize))), 0), f.Rows-1)
+	return row*f.Cols + col
+}
+
+// At returns the amount of vegetation in the cell holding x, y.
+func (f *Field) At(x, y float64) float64 {
+	if f == nil {
+		return 0
+	}
+	return f.Amounts[f.index(x, y)]
+}
+
+// Ripe tells whether the cell holding x, y is worth a full bite, what rays see as food.
+func (f *Field) Ripe(x, y float64) bool {
+	return f != nil && f.Amounts[f.index(x, y)] >= f.cfg.Bite
+}
+
+// Eat takes up to a bite, and at most hunger, from the cell holding x, y, in whole units of
+// energy. It returns the energy eaten.
+func (f *Field) Eat(x, y float64, hunger int) int {
+	if f == nil || hunger <= 0 {
+		return 0
+	}
+	i := f.index(x, y)
+	eaten := math.Floor(math.Min(math.Min(f.Amounts[i], f.cfg.Bite), float64(hunger)))
+	f.Amounts[i] -= eaten
+	return int(eaten)
+}
+
+// Capacity returns what a cell holds when fully grown at the given tick.
+func (f *Field) Capacity(tick uint64) float64 {
+	if f.cfg.SeasonAmplitude == 0 {
+		return f.cfg.Capacity
+	}
+	season := math.Sin(2 * math.Pi * float64(tick%uint64(f.cfg.SeasonPeriod)) / float64(f.cfg.SeasonPeriod))
+	return f.cfg.Capacity * (1 + f.cfg.SeasonAmplitude*season)
+}
+
+// Grow moves every cell a Regrowth fraction of the way towards the capacity of the tick, which
+// also withers the cells above it when the capacity drops.
+func (f *Field) Grow(tick uint64) {
+	if f == nil {
+		return
+	}
+	capacity := f.Capacity(tick)
+	for i, amount := range f.Amounts {
+		f.Amounts[i] = amount + f.cfg.Regrowth*(capacity-amount)
+	}
+}
+
+// Heatmap averages the field over blocks of scale x scale cells.
+func (f *Field) Heatmap(scale int) Heatmap {
+	h := Heatmap{CellSize: f.CellSize * scale, Cols: (f.Cols + scale - 1) / scale, Rows: (f.Rows + scale - 1) / scale}
+	sums := make([]float64, h.Cols*h.Rows)
+	counts := make([]int, h.Cols*h.Rows)
+	for row := 0; row < f.Rows; row++ {
+		for col := 0; col < f.Cols; col++ {
+			i := (row/scale)*h.Cols + col/scale
+			sums[i] += f.Amounts[row*f.Cols+col]
+			counts[i]++
+		}
+	}
+	// the capacity peaks at (1 + SeasonAmplitude) times the configured one
+	peak := f.cfg.Capacity * (1 + f.cfg.SeasonAmplitude)
+	h.Values = make([]byte, len(sums))
+	for i, sum := range sums {
+		h.Values[i] = byte(math.Round(255 * math.Min(1, sum/float64(counts[i])/peak)))
+	}
+	return h
+}
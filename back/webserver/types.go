@@ -1,6 +1,9 @@
 package webserver
 
-
 type SelectRequest struct {
 	AgentId uint32 `json:"agentId"`
-}
\ No newline at end of file
+}
+
+type SpawnResponse struct {
+	Spawned []uint32 `json:"spawned"`
+}
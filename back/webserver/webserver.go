@@ -1,13 +1,19 @@
 package webserver
 
 import (
+	"Prey_Predator_MAS/Brain"
 	"Prey_Predator_MAS/agents"
 	"Prey_Predator_MAS/config"
+	"Prey_Predator_MAS/environment"
 	"Prey_Predator_MAS/simulation"
+	"Prey_Predator_MAS/terrain"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -25,23 +31,22 @@ type WebServer struct {
 	address       string
 	port          string
 	simulation    *simulation.Simulation
-	selectedAgent *agents.Agent
-	isPaused      bool
+	selectedAgent atomic.Uint32 // ID of the agent sent with its brain, 0 for none
+	isPaused      atomic.Bool
 }
 
 type SentData struct {
-	Agents        []*agents.AgentViewModel `json:"agents"`
-	TickCounter   uint64                   `json:"tickcounter"`
-	PreyCount     int                      `json:"preycount"`
-	PredatorCount int                      `json:"predatorcount"`
-	ElapsedTime   int64                    `json:"elapsedtime"`
+	Agents      []*agents.AgentViewModel `json:"agents"`
+	TickCounter uint64                   `json:"tickcounter"`
+	Populations map[string]int           `json:"populations"` // living agents by species name
+	ElapsedTime int64                    `json:"elapsedtime"`
 }
 
-func NewWebServer(address string, port string) *WebServer {
+func NewWebServer(address string, port string, sim *simulation.Simulation) *WebServer {
 	return &WebServer{
 		address:    address,
 		port:       port,
-		simulation: simulation.NewSimulation(),
+		simulation: sim,
 	}
 }
 
@@ -64,41 +69,27 @@ func (wserver *WebServer) handleConnections(w http.ResponseWriter, r *http.Reque
 
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Fatal(err)
+		log.Println("websocket upgrade failed:", err)
+		return
 	}
 	defer ws.Close()
 
 	for {
-		if wserver.isPaused {
+		if wserver.isPaused.Load() {
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
-		var agentsViewModels []*agents.AgentViewModel
-		var selectedAgentVM *agents.AgentViewModel = nil
+		// one environment per frame, a snapshot may replace it meanwhile
+		env := wserver.simulation.Environment()
+		agentsViewModels := env.AgentViewModels(wserver.selectedAgent.Load())
 
-		if wserver.selectedAgent != nil {
-			selectedAgentVM = agents.NewAgentViewModel(wserver.selectedAgent, true)
-		}
-
-		for _, agent := range wserver.simulation.Environment.Agents {
-			if agent != wserver.selectedAgent && agent != nil {
-				vm := agents.NewAgentViewModel(agent, false)
-				agentsViewModels = append(agentsViewModels, vm)
-			}
-		}
-
-		if selectedAgentVM != nil {
-			agentsViewModels = append(agentsViewModels, selectedAgentVM)
-		}
-
-		wserver.simulation.Environment.LongPollIterationEnd()
+		env.LongPollIterationEnd()
 
 		data := SentData{
-			Agents:        agentsViewModels,
-			TickCounter:   wserver.simulation.Environment.TickCounter,
-			PreyCount:     wserver.simulation.Environment.PreyCount,
-			PredatorCount: wserver.simulation.Environment.PredatorCount,
-			ElapsedTime:   time.Since(wserver.simulation.Environment.StartTime).Milliseconds(),
+			Agents:      agentsViewModels,
+			TickCounter: env.TickCounter,
+			Populations: populations(env),
+			ElapsedTime: time.Since(env.StartTime).Milliseconds(),
 		}
 
 		err = ws.WriteJSON(data)
@@ -109,6 +100,15 @@ func (wserver *WebServer) handleConnections(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// populations returns the number of living agents of each species of env, by name.
+func populations(env *environment.Environment) map[string]int {
+	populations := make(map[string]int, len(env.Populations))
+	for i, species := range env.Config().Species {
+		populations[species.Name] = env.Populations[i]
+	}
+	return populations
+}
+
 func (wserver *WebServer) selectAgentInfo(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != "POST" {
@@ -123,11 +123,9 @@ func (wserver *WebServer) selectAgentInfo(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	for _, element := range wserver.simulation.Environment.Agents {
-		if element.ID == uint32(selectRequest.AgentId) {
-			wserver.selectedAgent = element
-			return
-		}
+	if wserver.simulation.Environment().HasAgent(uint32(selectRequest.AgentId)) {
+		wserver.selectedAgent.Store(uint32(selectRequest.AgentId))
+		return
 	}
 	w.Write([]byte("Agent not found"))
 }
@@ -140,7 +138,94 @@ func (wserver *WebServer) getConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	val, err := json.Marshal(config.GetDefaultConfig())
+	// the terrain map is sent along the configuration so that clients can draw it
+	env := wserver.simulation.Environment()
+	val, err := json.Marshal(struct {
+		config.Config
+		Map *terrain.Map `json:"map,omitempty"`
+	}{env.Config(), env.Terrain()})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(val)
+}
+
+// species lists the living species with their size, founder and age.
+func (wserver *WebServer) species(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	val, err := json.Marshal(wserver.simulation.Environment().Species())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(val)
+}
+
+// mutations lists how often each mutation operator fired and was inherited.
+func (wserver *WebServer) mutations(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	val, err := json.Marshal(wserver.simulation.Environment().MutationCounts())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(val)
+}
+
+// mutationParams summarizes by species the mutation parameters evolved in the self-adaptive mode.
+func (wserver *WebServer) mutationParams(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	val, err := json.Marshal(wserver.simulation.Environment().MutationParams())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(val)
+}
+
+// vegetation sends the vegetation heatmap, every value averaging scale x scale vegetation cells.
+func (wserver *WebServer) vegetation(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	scale := 4
+	if value := r.URL.Query().Get("scale"); value != "" {
+		var err error
+		if scale, err = strconv.Atoi(value); err != nil || scale < 1 {
+			http.Error(w, "scale must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	heatmap, ok := wserver.simulation.Environment().Vegetation(scale)
+	if !ok {
+		http.Error(w, "vegetation is disabled, see vegetation.enabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	val, err := json.Marshal(heatmap)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -155,7 +240,7 @@ func (wserver *WebServer) pause(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	wserver.isPaused = true
+	wserver.isPaused.Store(true)
 }
 
 func (wserver *WebServer) play(w http.ResponseWriter, r *http.Request) {
@@ -165,7 +250,112 @@ func (wserver *WebServer) play(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	wserver.isPaused = false
+	wserver.isPaused.Store(false)
+}
+
+// snapshot downloads the current world on GET and replaces it with the uploaded one on POST.
+func (wserver *WebServer) snapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		env := wserver.simulation.Environment()
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"snapshot-%d.json.gz\"", env.TickCounter))
+		if err := env.Save(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case "POST":
+		env, err := environment.Load(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		wserver.selectedAgent.Store(0)
+		wserver.simulation.Replace(env)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// brain downloads the genome of the selected agent, or of ?agentId=, as ?format=json|binary.
+func (wserver *WebServer) brain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format, err := Brain.ParseGenomeFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := wserver.selectedAgent.Load()
+	if idParam := r.URL.Query().Get("agentId"); idParam != "" {
+		parsed, err := strconv.ParseUint(idParam, 10, 32)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id = uint32(parsed)
+	}
+	brain, species, ok, err := wserver.simulation.Environment().AgentBrain(id)
+	if !ok {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := brain.MarshalGenome(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if format == Brain.GenomeBinary {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"brain-%d-%s.genome\"", id, species))
+	w.Write(data)
+}
+
+// spawn adds ?count= agents of the ?species= whose brain is the uploaded genome (json or binary).
+func (wserver *WebServer) spawn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	count := 1
+	if countParam := r.URL.Query().Get("count"); countParam != "" {
+		var err error
+		if count, err = strconv.Atoi(countParam); err != nil || count < 1 {
+			http.Error(w, "count must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, 16<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	brain, err := Brain.UnmarshalGenome(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids, err := wserver.simulation.Environment().SpawnAgents(r.URL.Query().Get("species"), brain, count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SpawnResponse{Spawned: ids})
 }
 
 func (wserver *WebServer) Start() {
@@ -178,6 +368,13 @@ func (wserver *WebServer) Start() {
 	mux.Handle("/selectAgent", enableCORS(http.HandlerFunc(wserver.selectAgentInfo)))
 	mux.Handle("/pause", enableCORS(http.HandlerFunc(wserver.pause)))
 	mux.Handle("/play", enableCORS(http.HandlerFunc(wserver.play)))
+	mux.Handle("/snapshot", enableCORS(http.HandlerFunc(wserver.snapshot)))
+	mux.Handle("/brain", enableCORS(http.HandlerFunc(wserver.brain)))
+	mux.Handle("/spawn", enableCORS(http.HandlerFunc(wserver.spawn)))
+	mux.Handle("/species", enableCORS(http.HandlerFunc(wserver.species)))
+	mux.Handle("/mutations", enableCORS(http.HandlerFunc(wserver.mutations)))
+	mux.Handle("/mutationParams", enableCORS(http.HandlerFunc(wserver.mutationParams)))
+	mux.Handle("/vegetation", enableCORS(http.HandlerFunc(wserver.vegetation)))
 
 	// création du serveur http
 	s := &http.Server{
@@ -23,6 +23,14 @@ function getRandomName() {
     return name
 }
 
+// colors of the species in the config, "Red" and "Green" being those of the sprites
+const COLORS = {Red: 0xFF0000, Green: 0x00FF00, Blue: 0x3A7BD5, Yellow: 0xFFFF00, Orange: 0xFFA500, Purple: 0xA020F0, White: 0xFFFFFF};
+
+// hunts tells whether a species eats other agents, hunters are drawn as spiders
+function hunts(species) {
+    return species.diet.some((food) => food !== 'vegetation');
+}
+
 function scaleAgent(newAgent) {
     newAgent.pos[0] *= SCALING;
     newAgent.pos[1] *= SCALING;
@@ -42,7 +50,7 @@ function scaleAgent(newAgent) {
 }
 
 class Application {
-    constructor(agentCount, height, width, cellSize, agentRadius, predatorRayAngleDeg, preyRayAngleDeg, predatorRayMaxLength, preyRayMaxLength) {
+    constructor(agentCount, height, width, cellSize, agentRadius, species, terrainMap, vegetationEnabled) {
         this.agents = new Map();
         this.serverMessageCount = 0;
         this.socket = new WebSocket("ws://localhost:8080/ws");
@@ -58,10 +66,9 @@ class Application {
         this.mousePos = {x: 0, y: 0};
         this.rayLines = null;
         this.directionLine = null;
-        this.predatorRayAngleDeg = predatorRayAngleDeg
-        this.preyRayAngleDeg = preyRayAngleDeg
-        this.PredatorRayMaxLength = predatorRayMaxLength
-        this.PreyRayMaxLength = preyRayMaxLength
+        this.species = new Map(species.map((s) => [s.name, s]))
+        this.terrainMap = terrainMap
+        this.vegetationEnabled = vegetationEnabled
     }
 
     initialize() {
@@ -74,6 +81,8 @@ class Application {
         this.addMouseInteractions();
         this.addTickerUpdates();
         this.background = this.setUpBackgroundImage();
+        this.terrain = this.setUpTerrain();
+        this.vegetation = this.setUpVegetation();
         this.sapinsImage = this.setUpSapinsImage();
     }
 
@@ -95,6 +104,57 @@ class Application {
         return background;
     }
 
+    setUpTerrain() {
+        // colors of the terrain kinds: open, wall, tree, water and safe zone
+        const colors = [null, 0x333333, 0x1E5A1E, 0x3A7BD5, 0xF2E394];
+        const terrain = new PIXI.Graphics();
+        if (!this.terrainMap) {
+            return terrain;
+        }
+
+        // the cells are sent as base64, one byte per cell, row by row
+        const cells = atob(this.terrainMap.cells);
+        const size = this.terrainMap.cellSize * SCALING;
+        for (let row = 0; row < this.terrainMap.rows; row++) {
+            for (let col = 0; col < this.terrainMap.cols; col++) {
+                const color = colors[cells.charCodeAt(row * this.terrainMap.cols + col)];
+                if (color) {
+                    terrain.beginFill(color, 0.6);
+                    terrain.drawRect(col * size, row * size, size, size);
+                    terrain.endFill();
+                }
+            }
+        }
+
+        this.particleContainer.addChild(terrain);
+        return terrain;
+    }
+
+    setUpVegetation() {
+        const vegetation = new PIXI.Graphics();
+        this.particleContainer.addChild(vegetation);
+        if (!this.vegetationEnabled) {
+            return vegetation;
+        }
+
+        // the heatmap values are sent as base64, one byte per cell from 0 (bare) to 255 (fully grown)
+        const update = () => fetch('http://localhost:8080/vegetation?scale=4').then(response => response.json()).then(heatmap => {
+            const values = atob(heatmap.values);
+            const size = heatmap.cellSize * SCALING;
+            vegetation.clear();
+            for (let row = 0; row < heatmap.rows; row++) {
+                for (let col = 0; col < heatmap.cols; col++) {
+                    vegetation.beginFill(0x2E7D32, 0.5 * values.charCodeAt(row * heatmap.cols + col) / 255);
+                    vegetation.drawRect(col * size, row * size, size, size);
+                    vegetation.endFill();
+                }
+            }
+        });
+        update();
+        setInterval(update, 2000);
+        return vegetation;
+    }
+
     setUpSapinsImage() {
         const sapinsImage = new PIXI.Sprite.from('./img/sapins.png');
 
@@ -168,13 +228,15 @@ class Application {
             let agent;
             let agid = this.agents.get(newAgent.id);
 
-            if (newAgent.color === 'Red') {
+            const species = this.species.get(newAgent.species);
+            if (hunts(species)) {
                 if (agid) agent = agid;
                 else {
                     agent = new PIXI.Sprite.from('./img/phidippus.png');
                     agent.width = 50;
                     agent.height = 50;
                     agent.name = getRandomName();
+                    if (species.color !== 'Red' && COLORS[species.color]) agent.tint = COLORS[species.color];
                 }
             } else {
                 if (agid) agent = agid;
@@ -182,6 +244,7 @@ class Application {
                     agent = new PIXI.Sprite.from('./img/drosophile.png');
                     agent.width = 40;
                     agent.height = 40;
+                    if (species.color !== 'Green' && COLORS[species.color]) agent.tint = COLORS[species.color];
                 }
             }
 
@@ -359,8 +422,9 @@ class Application {
     updateInfos(data) {
         document.getElementById("elapsedtime").innerHTML = this.formatTime(data.elapsedtime);
         document.getElementById("tickscount").innerHTML = data.tickcounter;
-        document.getElementById("phidicount").innerHTML = data.predatorcount;
-        document.getElementById("preycount").innerHTML = data.preycount;
+        document.getElementById("populations").innerHTML = [...this.species.keys()]
+            .map((name) => `<p><b>Number of ${name}: </b>${data.populations[name] ?? 0}</p>`)
+            .join("");
     }
 
     formatTime(elapsedtime) {
@@ -393,27 +457,16 @@ class Application {
         let startAngle
         let angleDelta
 
-        if (newAgent.color === "Red") {
-            sameTypeColor = 0xFF0000;
-            otherColors = 0x00FF00;
-            maxRayLength = this.PredatorRayMaxLength
-
-            angleRadian = this.predatorRayAngleDeg * (Math.PI / 180);
-            startAngle = Math.atan2(newAgent.vel[1], newAgent.vel[0]) - angleRadian / 2;
-            angleDelta = angleRadian / rayLengths.length;
+        const species = this.species.get(newAgent.species);
+        sameTypeColor = COLORS[species.color] ?? 0xFFFFFF;
+        otherColors = species.color === "Red" ? 0x00FF00 : 0xFF0000;
+        maxRayLength = species.rayLength
 
-            rayAngleStep = this.predatorRayAngleDeg / rayLengths.length;
-        } else {
-            sameTypeColor = 0x00FF00;
-            otherColors = 0xFF0000;
-            maxRayLength = this.PreyRayMaxLength
+        angleRadian = species.rayAngleDeg * (Math.PI / 180);
+        startAngle = Math.atan2(newAgent.vel[1], newAgent.vel[0]) - angleRadian / 2;
+        angleDelta = angleRadian / (rayLengths.length - 1);
 
-            angleRadian = this.preyRayAngleDeg * (Math.PI / 180);
-            startAngle = Math.atan2(newAgent.vel[1], newAgent.vel[0]) - angleRadian / 2;
-            angleDelta = angleRadian / (rayLengths.length - 1);
-
-            rayAngleStep = this.preyRayAngleDeg / rayLengths.length;
-        }
+        rayAngleStep = species.rayAngleDeg / rayLengths.length;
 
         let counter = 0;
         rayLengths.forEach((length) => {
@@ -570,7 +623,7 @@ document.addEventListener("DOMContentLoaded", function() {
             'Content-Type': 'application/json'
         }}).then(response => response.json().then(data => {
             console.log(data);  
-            const app = new Application(data.numAgents, data.height * SCALING, data.width * SCALING, data.cellSize * SCALING, data.agentRadius * SCALING * 2, data.predatorRayAngleDeg, data.preyRayAngleDeg,data.predatorRayLength, data.preyRayLength);
+            const app = new Application(data.numAgents, data.height * SCALING, data.width * SCALING, data.cellSize * SCALING, data.agentRadius * SCALING * 2, data.species, data.map, data.vegetation.enabled);
             app.initialize();
         })
     );